@@ -0,0 +1,197 @@
+// Package cluster contains the catalogue of NAIS clusters that applications are migrated to.
+package cluster
+
+import (
+	"fmt"
+	"github.com/nais/migrator/models/naisd"
+	"strings"
+)
+
+// ZONE_GCP is not a naisd zone, but selects the Google Cloud Platform clusters.
+const ZONE_GCP = "gcp"
+
+// Cluster describes a Naiserator cluster, and the conventions applications running in it should follow.
+type Cluster struct {
+	// Name of the cluster, as used by kubectl contexts and deploy tooling.
+	Name string
+	// Zone is the naisd zone served by this cluster.
+	Zone string
+	// Production is true for clusters serving the production environment class.
+	Production bool
+	// GCP is true for clusters running in Google Cloud Platform.
+	GCP bool
+	// IngressDomains lists domains that ingresses may use in this cluster.
+	// The first domain is used for automatically generated ingresses.
+	IngressDomains []string
+	// VaultKvPrefix is the Vault KV path under which application secrets are stored.
+	VaultKvPrefix string
+	// VaultDatabaseMount is the Vault secrets engine issuing dynamic PostgreSQL credentials, if available.
+	VaultDatabaseMount string
+	// Webproxy is true if outbound traffic through the webproxy is available.
+	Webproxy bool
+}
+
+var catalogue = []Cluster{
+	{
+		Name:               "dev-fss",
+		Zone:               naisd.ZONE_FSS,
+		IngressDomains:     []string{"nais.preprod.local", "dev.adeo.no", "dev.intern.nav.no"},
+		VaultKvPrefix:      "/kv/preprod/fss",
+		VaultDatabaseMount: "/postgresql/preprod-fss",
+		Webproxy:           true,
+	},
+	{
+		Name:               "prod-fss",
+		Zone:               naisd.ZONE_FSS,
+		Production:         true,
+		IngressDomains:     []string{"nais.adeo.no", "intern.nav.no"},
+		VaultKvPrefix:      "/kv/prod/fss",
+		VaultDatabaseMount: "/postgresql/prod-fss",
+		Webproxy:           true,
+	},
+	{
+		Name:               "dev-sbs",
+		Zone:               naisd.ZONE_SBS,
+		IngressDomains:     []string{"nais.oera-q.local", "dev.nav.no"},
+		VaultKvPrefix:      "/kv/preprod/sbs",
+		VaultDatabaseMount: "/postgresql/preprod-sbs",
+		Webproxy:           true,
+	},
+	{
+		Name:               "prod-sbs",
+		Zone:               naisd.ZONE_SBS,
+		Production:         true,
+		IngressDomains:     []string{"nais.oera.no", "nav.no"},
+		VaultKvPrefix:      "/kv/prod/sbs",
+		VaultDatabaseMount: "/postgresql/prod-sbs",
+		Webproxy:           true,
+	},
+	{
+		Name:           "dev-iapp",
+		Zone:           naisd.ZONE_IAPP,
+		IngressDomains: []string{"nais.oera-q.local"},
+		VaultKvPrefix:  "/kv/preprod/iapp",
+		Webproxy:       true,
+	},
+	{
+		Name:           "prod-iapp",
+		Zone:           naisd.ZONE_IAPP,
+		Production:     true,
+		IngressDomains: []string{"nais.oera.no"},
+		VaultKvPrefix:  "/kv/prod/iapp",
+		Webproxy:       true,
+	},
+	{
+		Name:           "dev-gcp",
+		Zone:           ZONE_GCP,
+		GCP:            true,
+		IngressDomains: []string{"dev.nav.no", "dev.intern.nav.no", "dev-gcp.nais.io"},
+		VaultKvPrefix:  "/kv/preprod/gcp",
+	},
+	{
+		Name:           "prod-gcp",
+		Zone:           ZONE_GCP,
+		Production:     true,
+		GCP:            true,
+		IngressDomains: []string{"nav.no", "intern.nav.no", "prod-gcp.nais.io"},
+		VaultKvPrefix:  "/kv/prod/gcp",
+	},
+}
+
+// All returns every cluster in the catalogue.
+func All() []Cluster {
+	return append([]Cluster{}, catalogue...)
+}
+
+// Zones returns the distinct zones served by the catalogue.
+func Zones() []string {
+	var zones []string
+	seen := make(map[string]bool)
+	for _, c := range catalogue {
+		if !seen[c.Zone] {
+			zones = append(zones, c.Zone)
+			seen[c.Zone] = true
+		}
+	}
+	return zones
+}
+
+// Production returns true if the Fasit environment belongs to the production environment class.
+func Production(fasitEnvironment string) bool {
+	return fasitEnvironment == naisd.ENVIRONMENT_P
+}
+
+// Lookup finds the cluster serving a zone in the given environment class.
+func Lookup(zone string, production bool) (Cluster, error) {
+	for _, c := range catalogue {
+		if c.Zone == zone && c.Production == production {
+			return c, nil
+		}
+	}
+	return Cluster{}, fmt.Errorf("no cluster serves zone '%s'; valid zones are %s", zone, strings.Join(Zones(), ", "))
+}
+
+// ForDeploy finds the cluster a naisd deployment request would be migrated to.
+func ForDeploy(deploy naisd.Deploy) (Cluster, error) {
+	return Lookup(deploy.Zone, Production(deploy.FasitEnvironment))
+}
+
+// AutoIngressDomain returns the domain used for automatically generated ingresses.
+func (c Cluster) AutoIngressDomain() string {
+	if len(c.IngressDomains) == 0 {
+		return ""
+	}
+	return c.IngressDomains[0]
+}
+
+// onPremDomains are domains that are only reachable from the on-premises zones.
+var onPremDomains = []string{"adeo.no", "preprod.local", "oera.no", "oera-q.local", "test.local", "devillo.no"}
+
+// OnPremHost returns true if the host belongs to a domain that is only reachable from on-premises clusters.
+func OnPremHost(host string) bool {
+	return inDomains(host, onPremDomains)
+}
+
+// naisDomains are domains served by the NAIS clusters themselves, such as automatic ingresses and Kubernetes services.
+var naisDomains = []string{"nais.adeo.no", "nais.preprod.local", "nais.oera.no", "nais.oera-q.local", "nais.io", "svc.nais.local", "cluster.local"}
+
+// NaisHost returns true if the host is served by one of the NAIS clusters, or is a Kubernetes service name without
+// a domain. Traffic to such hosts is not governed by external access rules.
+func NaisHost(host string) bool {
+	return !strings.Contains(host, ".") || inDomains(host, naisDomains)
+}
+
+// internalDomains are domains for services that are only reachable from the internal network.
+var internalDomains = []string{"adeo.no", "preprod.local", "test.local", "devillo.no", "intern.nav.no"}
+
+// SuggestDomain returns the ingress domain a host outside the cluster's domains should be moved to.
+// Internal hosts are moved to an internal domain of the cluster, such as intern.nav.no or dev.adeo.no,
+// and other hosts to the first domain that is not a nais.* domain. Internal hosts are never moved to a public domain;
+// if the cluster has no internal domain, the automatic ingress domain is suggested.
+func (c Cluster) SuggestDomain(host string) string {
+	internal := inDomains(host, internalDomains)
+	for _, domain := range c.IngressDomains {
+		if strings.HasPrefix(domain, "nais.") {
+			continue
+		}
+		if !internal || inDomains(domain, internalDomains) {
+			return domain
+		}
+	}
+	return c.AutoIngressDomain()
+}
+
+// AllowsHost returns true if the host belongs to one of the ingress domains of the cluster.
+func (c Cluster) AllowsHost(host string) bool {
+	return inDomains(host, c.IngressDomains)
+}
+
+func inDomains(host string, domains []string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, domain := range domains {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,66 @@
+package cluster
+
+import (
+	"github.com/nais/migrator/models/naisd"
+	"strings"
+	"testing"
+)
+
+func TestForDeploy(t *testing.T) {
+	tests := []struct {
+		zone             string
+		fasitEnvironment string
+		cluster          string
+		gcp              bool
+	}{
+		{zone: naisd.ZONE_FSS, fasitEnvironment: "q1", cluster: "dev-fss"},
+		{zone: naisd.ZONE_FSS, fasitEnvironment: "t6", cluster: "dev-fss"},
+		{zone: naisd.ZONE_FSS, fasitEnvironment: "p", cluster: "prod-fss"},
+		{zone: naisd.ZONE_SBS, fasitEnvironment: "q0", cluster: "dev-sbs"},
+		{zone: naisd.ZONE_SBS, fasitEnvironment: "p", cluster: "prod-sbs"},
+		{zone: naisd.ZONE_IAPP, fasitEnvironment: "q1", cluster: "dev-iapp"},
+		{zone: naisd.ZONE_IAPP, fasitEnvironment: "p", cluster: "prod-iapp"},
+		{zone: ZONE_GCP, fasitEnvironment: "q1", cluster: "dev-gcp", gcp: true},
+		{zone: ZONE_GCP, fasitEnvironment: "p", cluster: "prod-gcp", gcp: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.zone+"/"+test.fasitEnvironment, func(t *testing.T) {
+			c, err := ForDeploy(naisd.Deploy{Application: "app", Zone: test.zone, FasitEnvironment: test.fasitEnvironment})
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if c.Name != test.cluster {
+				t.Errorf("got cluster %s, want %s", c.Name, test.cluster)
+			}
+			if c.Zone != test.zone {
+				t.Errorf("cluster %s serves zone %s, want %s", c.Name, c.Zone, test.zone)
+			}
+			if c.Production != Production(test.fasitEnvironment) {
+				t.Errorf("cluster %s has production %v, want %v", c.Name, c.Production, Production(test.fasitEnvironment))
+			}
+			if c.GCP != test.gcp {
+				t.Errorf("cluster %s has GCP %v, want %v", c.Name, c.GCP, test.gcp)
+			}
+			if c.GCP && c.Webproxy {
+				t.Errorf("cluster %s in GCP has a webproxy", c.Name)
+			}
+			if len(c.AutoIngressDomain()) == 0 || len(c.VaultKvPrefix) == 0 {
+				t.Errorf("cluster %s has no automatic ingress domain or Vault prefix", c.Name)
+			}
+		})
+	}
+}
+
+func TestLookupUnknownZone(t *testing.T) {
+	for _, zone := range []string{"", "FSS", "azure"} {
+		_, err := Lookup(zone, false)
+		if err == nil {
+			t.Fatalf("expected an error for zone '%s'", zone)
+		}
+		expected := "no cluster serves zone '" + zone + "'; valid zones are fss, sbs, iapp, gcp"
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("got error '%s', want '%s'", err, expected)
+		}
+	}
+}
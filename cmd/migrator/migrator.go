@@ -2,31 +2,67 @@ package main
 
 import (
 	"fmt"
+	"github.com/nais/migrator/cluster"
+	"github.com/nais/migrator/config"
 	"github.com/nais/migrator/fasit"
+	"github.com/nais/migrator/input"
+	"github.com/nais/migrator/live"
 	"github.com/nais/migrator/mapper"
 	"github.com/nais/migrator/models/naisd"
 	"github.com/nais/migrator/models/naiserator"
+	"github.com/nais/migrator/patch"
+	"github.com/nais/migrator/redact"
+	"github.com/nais/migrator/render"
+	"github.com/nais/migrator/report"
 	log "github.com/sirupsen/logrus"
 	flag "github.com/spf13/pflag"
 	"gopkg.in/yaml.v2"
 	"io"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
 	"time"
 )
 
 type Config struct {
-	FasitURL string
-	Input    string
+	FasitURL           string
+	Input              string
+	ConfigFile         string
+	Patches            []string
+	OutputDir          string
+	Target             string
+	Report             string
+	ValuesFile         string
+	Set                []string
+	Preserve           []string
+	AllowUnknownFields bool
+	SecureLogs         bool
+	OutputFormat       string
+	Compare            []string
+	FromLive           []string
+	Redact             string
+	RedactFile         string
+	EnvCollisions      string
+	RewriteIngresses   bool
+	Buckets            []string
 }
 
+const (
+	formatApplication = "application"
+	formatKubernetes  = "kubernetes"
+)
+
 var (
 	cfg = Config{
-		FasitURL: "http://localhost:8080",
-		Input:    "-",
+		FasitURL:     "http://localhost:8080",
+		Input:        "-",
+		Target:       string(mapper.TargetOnPrem),
+		OutputFormat: formatApplication,
 	}
 	deploy = naisd.Deploy{
 		Application:      "myapplication",
-		Namespace:        "default",
 		Zone:             naisd.ZONE_FSS,
 		FasitEnvironment: naisd.ENVIRONMENT_P,
 	}
@@ -34,12 +70,87 @@ var (
 
 func init() {
 	flag.StringVar(&deploy.Application, "application", deploy.Application, "application name")
-	flag.StringVar(&deploy.Zone, "zone", deploy.Zone, "zone (fss, sbs)")
+	flag.StringVar(&deploy.Zone, "zone", deploy.Zone, "zone ("+strings.Join(cluster.Zones(), ", ")+")")
+	flag.StringVar(&deploy.Namespace, "namespace", deploy.Namespace, "namespace; defaults to the configuration file, then to the team namespace")
 	flag.StringVar(&cfg.FasitURL, "fasit-url", cfg.FasitURL, "Fasit url")
 	flag.StringVar(&deploy.FasitUsername, "fasit-username", deploy.FasitUsername, "Fasit username; leave blank to disable Fasit")
 	flag.StringVar(&deploy.FasitPassword, "fasit-password", deploy.FasitPassword, "Fasit password")
 	flag.StringVar(&deploy.FasitEnvironment, "fasit-environment", deploy.FasitEnvironment, "Fasit environment ([ptuo][0-9]*")
 	flag.StringVar(&cfg.Input, "input", cfg.Input, "Input file, use '-' for STDIN")
+	flag.StringArrayVar(&cfg.FromLive, "from-live", cfg.FromLive, "YAML file with objects exported from the cluster using 'kubectl get -o yaml', used as input instead of the NAIS manifest; may be repeated")
+	flag.StringVar(&cfg.ValuesFile, "values", cfg.ValuesFile, "YAML file with variables for rendering a templated input file")
+	flag.StringArrayVar(&cfg.Set, "set", cfg.Set, "Template variable on the form key=value, may be repeated")
+	flag.StringArrayVar(&cfg.Preserve, "preserve", cfg.Preserve, "Template variable to keep as a placeholder in the output, such as 'version'; may be repeated")
+	flag.BoolVar(&cfg.AllowUnknownFields, "allow-unknown-fields", cfg.AllowUnknownFields, "Report unknown fields in the input file instead of failing")
+	flag.StringArrayVar(&cfg.Patches, "patch", cfg.Patches, "Patch file applied to the converted application; JSON Patch or merge patch, may be repeated")
+	flag.BoolVar(&cfg.SecureLogs, "secure-logs", cfg.SecureLogs, "Enable secure logs for the application")
+	flag.StringVar(&cfg.Target, "target", cfg.Target, "Where the application will run ("+string(mapper.TargetOnPrem)+", "+string(mapper.TargetGCP)+")")
+	flag.StringVar(&cfg.Report, "report", cfg.Report, "Write the migration report to this file")
+	flag.StringVar(&cfg.EnvCollisions, "env-collisions", cfg.EnvCollisions, "What to do with environment variables with the same name ("+string(mapper.CollisionKeep)+", "+string(mapper.CollisionRename)+", "+string(mapper.CollisionFail)+"); defaults to "+string(mapper.CollisionKeep))
+	flag.BoolVar(&cfg.RewriteIngresses, "rewrite-ingresses", cfg.RewriteIngresses, "Move ingresses outside the cluster's domains to the suggested domain, instead of only reporting them")
+	flag.StringArrayVar(&cfg.Buckets, "bucket", cfg.Buckets, "Cloud Storage bucket used by the application when migrating to GCP; may be repeated")
+	flag.StringVar(&cfg.Redact, "redact", cfg.Redact, "Move sensitive environment variables out of the application ("+redact.ModeSecret+", "+redact.ModeVault+")")
+	flag.StringVar(&cfg.RedactFile, "redact-file", cfg.RedactFile, "Write redacted values to this file; defaults to <application>-secret.yaml or <application>-vault.json next to the output")
+	flag.StringVar(&cfg.OutputFormat, "output-format", cfg.OutputFormat, "Output format ("+formatApplication+", "+formatKubernetes+"); "+formatKubernetes+" renders the Deployment, Service and other objects Naiserator would create")
+	flag.StringArrayVar(&cfg.Compare, "compare", cfg.Compare, "YAML file with objects exported from the cluster using 'kubectl get -o yaml', to compare the result with; may be repeated")
+	flag.StringVar(&cfg.OutputDir, "output-dir", cfg.OutputDir, "Write output to <dir>/<cluster>/<application>.yaml instead of STDOUT")
+	flag.StringVar(&cfg.ConfigFile, "config", cfg.ConfigFile, "Configuration file; defaults to "+config.FileName+" in the current directory or any parent up to the repository root")
+}
+
+// loadConfigFile reads the configuration file and applies its defaults
+// to all settings that were not given explicitly on the command line.
+func loadConfigFile() (config.Config, error) {
+	var err error
+	path := cfg.ConfigFile
+
+	if len(path) == 0 {
+		path, err = config.Discover(".")
+		if err != nil {
+			return config.Config{}, fmt.Errorf("discover configuration file: %s", err)
+		}
+		if len(path) == 0 {
+			return config.Config{}, nil
+		}
+	}
+
+	file, err := config.Load(path)
+	if err != nil {
+		return config.Config{}, err
+	}
+
+	log.Infof("Using configuration file %s", path)
+
+	setDefault := func(name string, target *string, value string) {
+		if len(value) > 0 && !flag.CommandLine.Changed(name) {
+			*target = value
+		}
+	}
+
+	// The environment must be settled first, as it selects the environment specific section.
+	setDefault("fasit-environment", &deploy.FasitEnvironment, file.Deploy.FasitEnvironment)
+	file = file.Environment(deploy.FasitEnvironment)
+
+	setDefault("application", &deploy.Application, file.Deploy.Application)
+	setDefault("zone", &deploy.Zone, file.Deploy.Zone)
+	setDefault("fasit-username", &deploy.FasitUsername, file.Deploy.FasitUsername)
+	setDefault("fasit-url", &cfg.FasitURL, file.FasitURL)
+
+	return file, nil
+}
+
+// loadPatches reads the patches from the configuration file, followed by those given on the command line.
+func loadPatches(file config.Config) ([]patch.Patch, error) {
+	var patches []patch.Patch
+
+	for _, path := range append(file.Patches, cfg.Patches...) {
+		p, err := patch.Load(path)
+		if err != nil {
+			return nil, err
+		}
+		patches = append(patches, p)
+	}
+
+	return patches, nil
 }
 
 func main() {
@@ -59,31 +170,56 @@ func main() {
 
 func run() error {
 	var err error
-	var manifest naisd.NaisManifest
 	var application naiserator.Application
 	var fasitResources []fasit.NaisResource
-	var input io.Reader
+	var manifest naisd.NaisManifest
+	var placeholders input.Placeholders
+	var findings report.Report
+	var ingresses []string
+	var sensitive []string
 
-	log.Infoln("Reading NAIS manifest...")
+	file, err := loadConfigFile()
+	if err != nil {
+		return fmt.Errorf("load configuration: %s", err)
+	}
 
-	if cfg.Input == "-" {
-		input = os.Stdin
-	} else {
-		input, err = os.Open(cfg.Input)
-		if err != nil {
-			return fmt.Errorf("open file %s: %s", cfg.Input, err)
-		}
+	patches, err := loadPatches(file)
+	if err != nil {
+		return fmt.Errorf("load patches: %s", err)
 	}
 
-	decoder := yaml.NewDecoder(input)
-	err = decoder.Decode(&manifest)
+	target, err := mapper.ResolveCluster(deploy, mapper.Target(cfg.Target))
 	if err != nil {
-		return fmt.Errorf("decode input: %s", err)
+		return err
 	}
+	log.Infof("Migrating to cluster '%s'", target.Name)
 
-	log.Infoln("Finished reading NAIS manifest")
+	redactMode := file.Overrides.Redact.Mode
+	if flag.CommandLine.Changed("redact") {
+		redactMode = cfg.Redact
+	}
 
-	if len(deploy.FasitUsername) > 0 {
+	if len(cfg.FromLive) > 0 {
+		// Values of live secrets are only taken when they are redacted, so that they never end up in the output.
+		reconstructed, err := reconstructManifest(len(redactMode) > 0)
+		if err != nil {
+			return err
+		}
+		manifest = reconstructed.Manifest
+		findings = reconstructed.Report
+		fasitResources = append(fasitResources, reconstructed.Env)
+		ingresses = reconstructed.Ingresses
+		sensitive = reconstructed.Sensitive
+	} else {
+		manifest, placeholders, findings, err = readManifest()
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(deploy.FasitUsername) > 0 && len(cfg.FromLive) > 0 {
+		log.Warnf("Skipping Fasit, as the environment of the live deployment already contains the Fasit resources")
+	} else if len(deploy.FasitUsername) > 0 {
 		log.Infof("Fasit integration enabled, retrieving resources for application '%s' environment '%s' zone '%s'\n",
 			deploy.Application,
 			deploy.FasitEnvironment,
@@ -97,7 +233,7 @@ func run() error {
 		}
 
 		timer := time.Now()
-		fasitResources, err = fasit.FetchFasitResources(fasitClient, deploy.Application, deploy.FasitEnvironment, deploy.Zone, manifest.FasitResources.Used)
+		fasitResources, err = fasit.FetchFasitResources(fasitClient, deploy.Application, deploy.FasitEnvironment, deploy.Zone, manifest.FasitResources.Used, &findings)
 		elapsed := time.Since(timer)
 
 		if err != nil {
@@ -108,16 +244,282 @@ func run() error {
 		// os.Stderr.Write(d)
 	}
 
-	application = mapper.Convert(manifest, deploy, fasitResources)
+	overrides := file.Overrides
+	overrides.Ingresses = append(ingresses, overrides.Ingresses...)
+	overrides.SecureLogs = overrides.SecureLogs || cfg.SecureLogs
+	overrides.RewriteIngresses = overrides.RewriteIngresses || cfg.RewriteIngresses
+	overrides.Buckets = append(overrides.Buckets, cfg.Buckets...)
+	if flag.CommandLine.Changed("env-collisions") {
+		overrides.EnvCollisions = cfg.EnvCollisions
+	}
+
+	application, converted, err := mapper.Convert(manifest, deploy, fasitResources, mapper.Options{
+		Overrides: overrides,
+		Target:    mapper.Target(cfg.Target),
+		Cluster:   target,
+	})
+	findings.Merge(converted)
+	findings.Log()
+	if err != nil {
+		return fmt.Errorf("convert: %s", err)
+	}
+
+	application, err = patch.Apply(application, patches)
+	if err != nil {
+		return fmt.Errorf("apply patches: %s", err)
+	}
+	if len(patches) > 0 {
+		log.Infof("Applied %d patches to the converted application", len(patches))
+	}
+
+	overrides.Redact.Mode = redactMode
+	// Values read from live secrets are always sensitive.
+	for _, name := range sensitive {
+		overrides.Redact.Keys = append(overrides.Redact.Keys, "^"+regexp.QuoteMeta(name)+"$")
+	}
+
+	// Compare before redacting, as redacted variables are still part of the application's environment.
+	if len(cfg.Compare) > 0 {
+		liveObjects, err := live.Load(cfg.Compare)
+		if err != nil {
+			return err
+		}
+		classifier, err := redact.NewClassifier(overrides.Redact)
+		if err != nil {
+			return fmt.Errorf("redact: %s", err)
+		}
+		compared := live.Compare(liveObjects, application, target, classifier)
+		compared.Log()
+		findings.Merge(compared)
+	}
+
+	// Redact after patching, so that values added by patches are not written to the output.
+	application, err = redactSecrets(application, overrides.Redact, target, &findings)
+	if err != nil {
+		return err
+	}
 
-	log.Infoln("Conversion successful! Here is your Naiserator file:")
+	objects := []interface{}{application}
+	switch cfg.OutputFormat {
+	case formatApplication:
+	case formatKubernetes:
+		var rendered report.Report
+		objects, rendered = render.Kubernetes(application, target)
+		rendered.Log()
+		findings.Merge(rendered)
+	default:
+		return fmt.Errorf("unknown output format '%s'", cfg.OutputFormat)
+	}
 
-	os.Stdout.WriteString("---\n")
-	encoder := yaml.NewEncoder(os.Stdout)
-	err = encoder.Encode(application)
+	err = writeReport(findings)
 	if err != nil {
-		return fmt.Errorf("encode output: %s", err)
+		return err
+	}
+
+	output, err := openOutput(target, application.Name)
+	if err != nil {
+		return err
+	}
+	if output != os.Stdout {
+		defer output.Close()
+	}
+
+	for _, object := range objects {
+		data, err := yaml.Marshal(object)
+		if err != nil {
+			return fmt.Errorf("encode output: %s", err)
+		}
+
+		output.WriteString("---\n")
+		_, err = output.Write(placeholders.Restore(data))
+		if err != nil {
+			return fmt.Errorf("write output: %s", err)
+		}
 	}
 
 	return nil
 }
+
+// readManifest reads, renders and decodes the naisd manifest given as input.
+func readManifest() (naisd.NaisManifest, input.Placeholders, report.Report, error) {
+	var reader io.Reader
+	var err error
+
+	log.Infoln("Reading NAIS manifest...")
+
+	if cfg.Input == "-" {
+		reader = os.Stdin
+	} else {
+		reader, err = os.Open(cfg.Input)
+		if err != nil {
+			return naisd.NaisManifest{}, nil, report.Report{}, fmt.Errorf("open file %s: %s", cfg.Input, err)
+		}
+	}
+
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return naisd.NaisManifest{}, nil, report.Report{}, fmt.Errorf("read input: %s", err)
+	}
+
+	values, err := templateValues()
+	if err != nil {
+		return naisd.NaisManifest{}, nil, report.Report{}, err
+	}
+
+	data, placeholders, err := input.Render(data, values, cfg.Preserve)
+	if err != nil {
+		return naisd.NaisManifest{}, nil, report.Report{}, fmt.Errorf("render input: %s", err)
+	}
+
+	manifest, findings, err := input.Decode(data, deploy.Application, !cfg.AllowUnknownFields)
+	if err != nil {
+		return naisd.NaisManifest{}, nil, report.Report{}, fmt.Errorf("decode input: %s", err)
+	}
+
+	log.Infoln("Finished reading NAIS manifest")
+
+	return manifest, placeholders, findings, nil
+}
+
+// reconstructManifest builds the naisd manifest from objects exported from the cluster.
+// Values of live secrets are only taken if secrets is set.
+func reconstructManifest(secrets bool) (live.Input, error) {
+	log.Infof("Reconstructing NAIS manifest from live objects...")
+
+	objects, err := live.Load(cfg.FromLive)
+	if err != nil {
+		return live.Input{}, err
+	}
+
+	reconstructed, err := live.Reconstruct(objects, deploy.Application, secrets)
+	if err != nil {
+		return live.Input{}, fmt.Errorf("reconstruct manifest: %s", err)
+	}
+
+	return reconstructed, nil
+}
+
+// templateValues collects template variables from the values file and the command line.
+func templateValues() (input.Values, error) {
+	var err error
+	values := make(input.Values)
+
+	if len(cfg.ValuesFile) > 0 {
+		values, err = input.LoadValues(cfg.ValuesFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, assignment := range cfg.Set {
+		err = values.Set(assignment)
+		if err != nil {
+			return nil, fmt.Errorf("--set: %s", err)
+		}
+	}
+
+	return values, nil
+}
+
+// redactSecrets moves sensitive environment variables out of the application, and writes them to a separate file.
+func redactSecrets(application naiserator.Application, rules config.Redact, target cluster.Cluster, findings *report.Report) (naiserator.Application, error) {
+	var data []byte
+	var name string
+
+	if len(rules.Mode) == 0 {
+		return application, nil
+	}
+
+	classifier, err := redact.NewClassifier(rules)
+	if err != nil {
+		return application, fmt.Errorf("redact: %s", err)
+	}
+
+	application, redacted, rep := redact.Redact(application, classifier)
+	if len(redacted) == 0 {
+		log.Infof("No sensitive environment variables found")
+		return application, nil
+	}
+
+	switch rules.Mode {
+	case redact.ModeSecret:
+		name = application.Name + "-secret.yaml"
+		application, data, err = redact.ToSecret(application, redacted, &rep)
+	case redact.ModeVault:
+		name = application.Name + "-vault.json"
+		kvPath := fmt.Sprintf("%s/%s/%s/env", target.VaultKvPrefix, application.Name, application.Namespace)
+		application, data, err = redact.ToVault(application, redacted, kvPath, &rep)
+	default:
+		return application, fmt.Errorf("unknown redaction mode '%s'", rules.Mode)
+	}
+	if err != nil {
+		return application, fmt.Errorf("redact: %s", err)
+	}
+
+	path := cfg.RedactFile
+	if len(path) == 0 {
+		path = filepath.Join(cfg.OutputDir, target.Name, name)
+		if len(cfg.OutputDir) == 0 {
+			path = name
+		}
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return application, fmt.Errorf("create redaction directory: %s", err)
+	}
+	err = ioutil.WriteFile(path, data, 0600)
+	if err != nil {
+		return application, fmt.Errorf("write redacted values: %s", err)
+	}
+	log.Infof("%d redacted values written to %s; do not commit this file", len(redacted), path)
+
+	rep.Log()
+	findings.Merge(rep)
+
+	return application, nil
+}
+
+// writeReport writes the migration report to the file given on the command line, if any.
+func writeReport(findings report.Report) error {
+	if len(cfg.Report) == 0 {
+		return nil
+	}
+
+	file, err := os.Create(cfg.Report)
+	if err != nil {
+		return fmt.Errorf("create report file: %s", err)
+	}
+	defer file.Close()
+
+	err = findings.Write(file)
+	if err != nil {
+		return fmt.Errorf("write report: %s", err)
+	}
+
+	log.Infof("Migration report with %d findings written to %s", len(findings.Findings), cfg.Report)
+	return nil
+}
+
+// openOutput returns STDOUT, or a file laid out by cluster if an output directory is given.
+func openOutput(target cluster.Cluster, application string) (*os.File, error) {
+	if len(cfg.OutputDir) == 0 {
+		log.Infoln("Conversion successful! Here is your Naiserator file:")
+		return os.Stdout, nil
+	}
+
+	dir := filepath.Join(cfg.OutputDir, target.Name)
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, fmt.Errorf("create output directory: %s", err)
+	}
+
+	path := filepath.Join(dir, application+".yaml")
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("create output file: %s", err)
+	}
+
+	log.Infof("Conversion successful! Your Naiserator file is written to %s", path)
+	return file, nil
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"github.com/nais/migrator/fasit"
+	"github.com/nais/migrator/fasit/fasittest"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update expected output in testdata")
+
+// binary is the migrator built for the end-to-end tests.
+var binary string
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+
+	dir, err := ioutil.TempDir("", "migrator")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "create build directory: %s\n", err)
+		os.Exit(1)
+	}
+
+	binary = filepath.Join(dir, "migrator")
+	build := exec.Command("go", "build", "-o", binary, ".")
+	build.Stderr = os.Stderr
+	err = build.Run()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "build migrator: %s\n", err)
+		os.RemoveAll(dir)
+		os.Exit(1)
+	}
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// The cases share nais.yaml, migrator.yaml and the Fasit fixtures in fasit.json in testdata/e2e, and vary the
+// arguments and the Fasit fixtures. Successful runs are compared with naiserator.yaml and report.yaml in the
+// directory named after the case.
+var e2eCases = []struct {
+	name string
+	args []string
+	// failures are added to the Fasit fixtures, making API paths return the status code.
+	failures map[string]int
+	// missing are aliases of resources removed from the Fasit fixtures.
+	missing []string
+	// failure is part of the error expected from a failing run.
+	failure string
+}{
+	{name: "fss"},
+	{name: "gcp", args: []string{"--target", "gcp"}},
+	{name: "loadbalancer-failure", failures: map[string]int{"/api/v2/resources": 500}},
+	{name: "missing-resource", missing: []string{"mydb"}, failure: "unable to get resource mydb (datasource)"},
+	{name: "redact-compare", args: []string{"--patch", "api-token.yaml", "--redact", "secret", "--compare", "live.yaml"}},
+}
+
+func TestEndToEnd(t *testing.T) {
+	dir := filepath.Join("testdata", "e2e")
+
+	for _, c := range e2eCases {
+		t.Run(c.name, func(t *testing.T) {
+			fixtures, err := fasittest.Load(filepath.Join(dir, "fasit.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			fixtures.Failures = c.failures
+			fixtures.Resources = withoutAliases(fixtures.Resources, c.missing)
+			server := fasittest.NewServer(fixtures)
+			defer server.Close()
+
+			out, err := ioutil.TempDir("", "migrator-e2e")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(out)
+			reportFile := filepath.Join(out, "report.yaml")
+
+			redactFile := filepath.Join(out, "redacted.yaml")
+			args := append([]string{"--fasit-url", server.URL, "--input", "nais.yaml", "--report", reportFile, "--redact-file", redactFile}, c.args...)
+			var stdout, stderr bytes.Buffer
+			cmd := exec.Command(binary, args...)
+			cmd.Dir = dir
+			cmd.Stdout = &stdout
+			cmd.Stderr = &stderr
+			err = cmd.Run()
+
+			if len(c.failure) > 0 {
+				if err == nil {
+					t.Fatalf("expected the migration to fail with '%s'", c.failure)
+				}
+				if !strings.Contains(stderr.String(), c.failure) {
+					t.Fatalf("expected the error to contain '%s'\n%s", c.failure, stderr.String())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("migrator failed: %s\n%s", err, stderr.String())
+			}
+
+			findings, err := ioutil.ReadFile(reportFile)
+			if err != nil {
+				t.Fatalf("read report: %s", err)
+			}
+
+			compare(t, filepath.Join(dir, c.name, "naiserator.yaml"), stdout.Bytes())
+			compare(t, filepath.Join(dir, c.name, "report.yaml"), findings)
+		})
+	}
+}
+
+func withoutAliases(resources []fasit.FasitResource, aliases []string) []fasit.FasitResource {
+	var kept []fasit.FasitResource
+	for _, resource := range resources {
+		removed := false
+		for _, alias := range aliases {
+			removed = removed || resource.Alias == alias
+		}
+		if !removed {
+			kept = append(kept, resource)
+		}
+	}
+	return kept
+}
+
+// compare checks actual output against an expected file, or updates the file if the -update flag is given.
+func compare(t *testing.T, path string, actual []byte) {
+	if *update {
+		err := ioutil.WriteFile(path, actual, 0644)
+		if err != nil {
+			t.Fatalf("update %s: %s", path, err)
+		}
+		return
+	}
+
+	expected, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read expected output: %s", err)
+	}
+	if !bytes.Equal(expected, actual) {
+		t.Fatalf("output differs from %s; run with -update if the change is intended\n%s", path, actual)
+	}
+}
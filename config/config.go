@@ -0,0 +1,237 @@
+// Package config reads migrator.yaml, a file holding per-repository defaults and override rules for a migration.
+package config
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// FileName is the name of the configuration file looked for when discovering configuration.
+const FileName = "migrator.yaml"
+
+// Config is the top level structure of migrator.yaml.
+type Config struct {
+	Deploy       Deploy                 `yaml:"deploy"`
+	FasitURL     string                 `yaml:"fasitUrl"`
+	Overrides    Overrides              `yaml:"overrides"`
+	Patches      []string               `yaml:"patches"`
+	Environments map[string]Environment `yaml:"environments"`
+}
+
+// Deploy holds default values for the naisd deployment request.
+// Passwords are deliberately left out; they do not belong in a file committed to a repository.
+type Deploy struct {
+	Application      string `yaml:"application"`
+	Zone             string `yaml:"zone"`
+	FasitEnvironment string `yaml:"fasitEnvironment"`
+	FasitUsername    string `yaml:"fasitUsername"`
+}
+
+// Environment contains settings that only apply when migrating a specific Fasit environment.
+type Environment struct {
+	Deploy    Deploy    `yaml:"deploy"`
+	FasitURL  string    `yaml:"fasitUrl"`
+	Overrides Overrides `yaml:"overrides"`
+	Patches   []string  `yaml:"patches"`
+}
+
+// Overrides are rules applied by the mapper on top of the converted application.
+type Overrides struct {
+	// Namespace to deploy the application into.
+	Namespace string `yaml:"namespace"`
+	// Labels added to the application metadata.
+	Labels map[string]string `yaml:"labels"`
+	// Annotations added to the application metadata.
+	Annotations map[string]string `yaml:"annotations"`
+	// Ingresses appended to the automatically generated ones.
+	Ingresses []string `yaml:"ingresses"`
+	// RenameEnv maps generated environment variable names to the name the application expects.
+	RenameEnv map[string]string `yaml:"renameEnv"`
+	// DropResources lists Fasit resource aliases that should not be part of the migrated application.
+	DropResources []string `yaml:"dropResources"`
+	// SecureLogs enables shipping of the application's secure logs.
+	SecureLogs bool `yaml:"secureLogs"`
+	// Redact moves sensitive environment variables out of the application.
+	Redact Redact `yaml:"redact"`
+	// EnvCollisions decides what happens to environment variables with the same name; "keep", "rename" or "fail".
+	EnvCollisions string `yaml:"envCollisions"`
+	// RewriteIngresses moves ingresses outside the cluster's domains to the suggested domain, instead of only reporting them.
+	RewriteIngresses bool `yaml:"rewriteIngresses"`
+	// Buckets lists Cloud Storage buckets created for the application; only used when migrating to GCP.
+	Buckets []string `yaml:"buckets"`
+}
+
+// Redact controls how sensitive environment variables are recognized, and where they are moved.
+type Redact struct {
+	// Mode is where sensitive values are moved; "secret" for a Kubernetes secret or "vault" for Vault.
+	// Sensitive values are left in the application if empty.
+	Mode string `yaml:"mode"`
+	// Keys are additional regular expressions matching names of sensitive environment variables.
+	Keys []string `yaml:"keys"`
+	// Values are additional regular expressions matching sensitive values.
+	Values []string `yaml:"values"`
+	// Allow lists environment variables that are never considered sensitive.
+	Allow []string `yaml:"allow"`
+}
+
+// Merge returns a copy of the rules with those from other layered on top.
+func (r Redact) Merge(other Redact) Redact {
+	merged := Redact{
+		Mode:   r.Mode,
+		Keys:   append(append([]string{}, r.Keys...), other.Keys...),
+		Values: append(append([]string{}, r.Values...), other.Values...),
+		Allow:  append(append([]string{}, r.Allow...), other.Allow...),
+	}
+	if len(other.Mode) > 0 {
+		merged.Mode = other.Mode
+	}
+	return merged
+}
+
+// Merge returns a copy of the overrides with the values from other layered on top.
+// Scalars are replaced, maps are merged key by key and lists are appended.
+func (o Overrides) Merge(other Overrides) Overrides {
+	merged := Overrides{
+		Namespace:        o.Namespace,
+		Labels:           MergeMap(o.Labels, other.Labels),
+		Annotations:      MergeMap(o.Annotations, other.Annotations),
+		Ingresses:        append(append([]string{}, o.Ingresses...), other.Ingresses...),
+		RenameEnv:        MergeMap(o.RenameEnv, other.RenameEnv),
+		DropResources:    append(append([]string{}, o.DropResources...), other.DropResources...),
+		SecureLogs:       o.SecureLogs || other.SecureLogs,
+		Redact:           o.Redact.Merge(other.Redact),
+		EnvCollisions:    o.EnvCollisions,
+		RewriteIngresses: o.RewriteIngresses || other.RewriteIngresses,
+		Buckets:          append(append([]string{}, o.Buckets...), other.Buckets...),
+	}
+
+	if len(other.Namespace) > 0 {
+		merged.Namespace = other.Namespace
+	}
+	if len(other.EnvCollisions) > 0 {
+		merged.EnvCollisions = other.EnvCollisions
+	}
+
+	return merged
+}
+
+// Merge returns a copy of the deploy defaults with the non-empty values from other layered on top.
+func (d Deploy) Merge(other Deploy) Deploy {
+	if len(other.Application) > 0 {
+		d.Application = other.Application
+	}
+	if len(other.Zone) > 0 {
+		d.Zone = other.Zone
+	}
+	if len(other.FasitEnvironment) > 0 {
+		d.FasitEnvironment = other.FasitEnvironment
+	}
+	if len(other.FasitUsername) > 0 {
+		d.FasitUsername = other.FasitUsername
+	}
+	return d
+}
+
+// Environment returns the configuration that applies to the named Fasit environment,
+// with the environment section merged on top of the top level defaults.
+func (c Config) Environment(name string) Config {
+	env, ok := c.Environments[name]
+	if !ok {
+		return c
+	}
+
+	resolved := Config{
+		Deploy:       c.Deploy.Merge(env.Deploy),
+		FasitURL:     c.FasitURL,
+		Overrides:    c.Overrides.Merge(env.Overrides),
+		Patches:      append(append([]string{}, c.Patches...), env.Patches...),
+		Environments: c.Environments,
+	}
+
+	if len(env.FasitURL) > 0 {
+		resolved.FasitURL = env.FasitURL
+	}
+
+	return resolved
+}
+
+// Load reads and strictly decodes a configuration file.
+// Patch file names are resolved relative to the directory of the configuration file.
+func Load(path string) (Config, error) {
+	var cfg Config
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return cfg, fmt.Errorf("read %s: %s", path, err)
+	}
+
+	err = yaml.UnmarshalStrict(data, &cfg)
+	if err != nil {
+		return cfg, fmt.Errorf("decode %s: %s", path, err)
+	}
+
+	dir := filepath.Dir(path)
+	cfg.Patches = resolvePaths(dir, cfg.Patches)
+	for name, env := range cfg.Environments {
+		env.Patches = resolvePaths(dir, env.Patches)
+		cfg.Environments[name] = env
+	}
+
+	return cfg, nil
+}
+
+// Discover looks for a configuration file in dir and its parent directories,
+// stopping at the root of the git repository. An empty string is returned if no file is found.
+func Discover(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+
+	for {
+		path := filepath.Join(dir, FileName)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return "", nil
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+func resolvePaths(dir string, paths []string) []string {
+	for i, path := range paths {
+		if !filepath.IsAbs(path) {
+			paths[i] = filepath.Join(dir, path)
+		}
+	}
+	return paths
+}
+
+// MergeMap returns a new map with the entries of a, replaced and extended by those of b.
+// It returns nil if both maps are empty.
+func MergeMap(a, b map[string]string) map[string]string {
+	if len(a) == 0 && len(b) == 0 {
+		return nil
+	}
+
+	merged := make(map[string]string, len(a)+len(b))
+	for k, v := range a {
+		merged[k] = v
+	}
+	for k, v := range b {
+		merged[k] = v
+	}
+
+	return merged
+}
@@ -0,0 +1,397 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// tempDir creates a directory holding the given files, and returns its path.
+// Names ending in a slash are created as directories.
+func tempDir(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if strings.HasSuffix(name, "/") {
+			err = os.MkdirAll(path, 0755)
+		} else {
+			err = os.MkdirAll(filepath.Dir(path), 0755)
+			if err == nil {
+				err = ioutil.WriteFile(path, []byte(content), 0644)
+			}
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestRedactMerge(t *testing.T) {
+	base := Redact{Mode: "secret", Keys: []string{"salt"}, Values: []string{"^AKIA"}, Allow: []string{"PUBLIC_KEY"}}
+
+	tests := []struct {
+		name   string
+		other  Redact
+		merged Redact
+	}{
+		{
+			name:   "empty rules keep the base",
+			other:  Redact{},
+			merged: base,
+		},
+		{
+			name:  "the mode is replaced and lists are appended",
+			other: Redact{Mode: "vault", Keys: []string{"pepper"}, Values: []string{"^ghp_"}, Allow: []string{"TOKEN_URL"}},
+			merged: Redact{
+				Mode:   "vault",
+				Keys:   []string{"salt", "pepper"},
+				Values: []string{"^AKIA", "^ghp_"},
+				Allow:  []string{"PUBLIC_KEY", "TOKEN_URL"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			merged := base.Merge(test.other)
+			if !reflect.DeepEqual(merged, test.merged) {
+				t.Errorf("merged rules differ:\n got: %+v\nwant: %+v", merged, test.merged)
+			}
+		})
+	}
+
+	// Merging must not modify the rules merged into.
+	base.Merge(Redact{Keys: []string{"pepper"}})
+	if len(base.Keys) != 1 {
+		t.Errorf("merging modified the base rules: %+v", base)
+	}
+}
+
+func TestOverridesMerge(t *testing.T) {
+	base := Overrides{
+		Namespace:     "myteam",
+		Labels:        map[string]string{"team": "myteam", "tier": "backend"},
+		Ingresses:     []string{"https://app.dev.adeo.no"},
+		RenameEnv:     map[string]string{"FOO_URL": "FOO_BASE_URL"},
+		DropResources: []string{"unused"},
+		EnvCollisions: "rename",
+		Redact:        Redact{Mode: "secret", Keys: []string{"salt"}, Values: []string{"^AKIA"}, Allow: []string{"PUBLIC_KEY"}},
+		Buckets:       []string{"app-files"},
+	}
+
+	tests := []struct {
+		name   string
+		other  Overrides
+		merged Overrides
+	}{
+		{
+			name:  "empty overrides keep the base",
+			other: Overrides{},
+			merged: Overrides{
+				Namespace:     "myteam",
+				Labels:        map[string]string{"team": "myteam", "tier": "backend"},
+				Ingresses:     []string{"https://app.dev.adeo.no"},
+				RenameEnv:     map[string]string{"FOO_URL": "FOO_BASE_URL"},
+				DropResources: []string{"unused"},
+				EnvCollisions: "rename",
+				Redact:        Redact{Mode: "secret", Keys: []string{"salt"}, Values: []string{"^AKIA"}, Allow: []string{"PUBLIC_KEY"}},
+				Buckets:       []string{"app-files"},
+			},
+		},
+		{
+			name: "scalars are replaced, maps merged by key and lists appended",
+			other: Overrides{
+				Namespace:        "default",
+				Labels:           map[string]string{"tier": "frontend"},
+				Annotations:      map[string]string{"nais.io/owner": "myteam"},
+				Ingresses:        []string{"https://app.intern.nav.no"},
+				DropResources:    []string{"legacy"},
+				SecureLogs:       true,
+				EnvCollisions:    "fail",
+				RewriteIngresses: true,
+				Buckets:          []string{"app-archive"},
+			},
+			merged: Overrides{
+				Namespace:        "default",
+				Labels:           map[string]string{"team": "myteam", "tier": "frontend"},
+				Annotations:      map[string]string{"nais.io/owner": "myteam"},
+				Ingresses:        []string{"https://app.dev.adeo.no", "https://app.intern.nav.no"},
+				RenameEnv:        map[string]string{"FOO_URL": "FOO_BASE_URL"},
+				DropResources:    []string{"unused", "legacy"},
+				SecureLogs:       true,
+				Redact:           Redact{Mode: "secret", Keys: []string{"salt"}, Values: []string{"^AKIA"}, Allow: []string{"PUBLIC_KEY"}},
+				EnvCollisions:    "fail",
+				RewriteIngresses: true,
+				Buckets:          []string{"app-files", "app-archive"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			merged := base.Merge(test.other)
+			if !reflect.DeepEqual(merged, test.merged) {
+				t.Errorf("merged overrides differ:\n got: %+v\nwant: %+v", merged, test.merged)
+			}
+		})
+	}
+
+	// Flags that are set can not be unset by a later layer.
+	if merged := (Overrides{SecureLogs: true}).Merge(Overrides{}); !merged.SecureLogs {
+		t.Error("secure logs were disabled by overrides that do not set them")
+	}
+}
+
+func TestDeployMerge(t *testing.T) {
+	base := Deploy{Application: "app", Zone: "fss", FasitEnvironment: "q1", FasitUsername: "srvapp"}
+
+	tests := []struct {
+		name   string
+		other  Deploy
+		merged Deploy
+	}{
+		{name: "empty values keep the base", other: Deploy{}, merged: base},
+		{
+			name:   "non-empty values are replaced",
+			other:  Deploy{Zone: "sbs", FasitEnvironment: "p"},
+			merged: Deploy{Application: "app", Zone: "sbs", FasitEnvironment: "p", FasitUsername: "srvapp"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if merged := base.Merge(test.other); merged != test.merged {
+				t.Errorf("merged deploy differs:\n got: %+v\nwant: %+v", merged, test.merged)
+			}
+		})
+	}
+}
+
+func TestEnvironment(t *testing.T) {
+	cfg := Config{
+		Deploy:    Deploy{Application: "app", Zone: "fss", FasitUsername: "srvapp"},
+		FasitURL:  "https://fasit.adeo.no",
+		Overrides: Overrides{Labels: map[string]string{"tier": "backend"}, Ingresses: []string{"https://app.dev.adeo.no"}},
+		Patches:   []string{"/repo/common.yaml"},
+		Environments: map[string]Environment{
+			"p": {
+				Deploy:    Deploy{FasitUsername: "srvapp-prod"},
+				FasitURL:  "https://fasit-prod.adeo.no",
+				Overrides: Overrides{Labels: map[string]string{"tier": "critical"}, Ingresses: []string{"https://app.adeo.no"}},
+				Patches:   []string{"/repo/prod.yaml"},
+			},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		environment string
+		deploy      Deploy
+		fasitURL    string
+		labels      map[string]string
+		ingresses   []string
+		patches     []string
+	}{
+		{
+			name:        "the environment section takes precedence",
+			environment: "p",
+			deploy:      Deploy{Application: "app", Zone: "fss", FasitUsername: "srvapp-prod"},
+			fasitURL:    "https://fasit-prod.adeo.no",
+			labels:      map[string]string{"tier": "critical"},
+			ingresses:   []string{"https://app.dev.adeo.no", "https://app.adeo.no"},
+			patches:     []string{"/repo/common.yaml", "/repo/prod.yaml"},
+		},
+		{
+			name:        "environments without a section use the top level",
+			environment: "q1",
+			deploy:      Deploy{Application: "app", Zone: "fss", FasitUsername: "srvapp"},
+			fasitURL:    "https://fasit.adeo.no",
+			labels:      map[string]string{"tier": "backend"},
+			ingresses:   []string{"https://app.dev.adeo.no"},
+			patches:     []string{"/repo/common.yaml"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resolved := cfg.Environment(test.environment)
+			if resolved.Deploy != test.deploy {
+				t.Errorf("deploy is %+v, want %+v", resolved.Deploy, test.deploy)
+			}
+			if resolved.FasitURL != test.fasitURL {
+				t.Errorf("Fasit URL is '%s', want '%s'", resolved.FasitURL, test.fasitURL)
+			}
+			if !reflect.DeepEqual(resolved.Overrides.Labels, test.labels) {
+				t.Errorf("labels are %v, want %v", resolved.Overrides.Labels, test.labels)
+			}
+			if !reflect.DeepEqual(resolved.Overrides.Ingresses, test.ingresses) {
+				t.Errorf("ingresses are %v, want %v", resolved.Overrides.Ingresses, test.ingresses)
+			}
+			if !reflect.DeepEqual(resolved.Patches, test.patches) {
+				t.Errorf("patches are %v, want %v", resolved.Patches, test.patches)
+			}
+		})
+	}
+
+	// Resolving an environment must not modify the top level configuration.
+	if len(cfg.Overrides.Ingresses) != 1 || len(cfg.Patches) != 1 {
+		t.Errorf("resolving an environment modified the configuration: %+v", cfg)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := tempDir(t, map[string]string{
+		"app/migrator.yaml": `
+deploy:
+  application: app
+  zone: fss
+patches:
+  - patches/common.yaml
+  - /etc/migrator/absolute.yaml
+environments:
+  p:
+    patches:
+      - ../shared/prod.yaml
+`,
+	})
+	defer os.RemoveAll(dir)
+
+	cfg, err := Load(filepath.Join(dir, "app", FileName))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if cfg.Deploy.Application != "app" || cfg.Deploy.Zone != "fss" {
+		t.Errorf("unexpected deploy defaults: %+v", cfg.Deploy)
+	}
+
+	// Relative patch paths are resolved against the directory of the configuration file.
+	patches := []string{filepath.Join(dir, "app", "patches", "common.yaml"), "/etc/migrator/absolute.yaml"}
+	if !reflect.DeepEqual(cfg.Patches, patches) {
+		t.Errorf("patches are %v, want %v", cfg.Patches, patches)
+	}
+	prod := []string{filepath.Join(dir, "shared", "prod.yaml")}
+	if !reflect.DeepEqual(cfg.Environments["p"].Patches, prod) {
+		t.Errorf("environment patches are %v, want %v", cfg.Environments["p"].Patches, prod)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		error   string
+	}{
+		{
+			name:    "unknown top level key",
+			content: "deploy:\n  application: app\nfasitURL: https://fasit.adeo.no\n",
+			error:   "field fasitURL not found",
+		},
+		{
+			name:    "unknown override",
+			content: "overrides:\n  labels:\n    team: myteam\n  ingress:\n    - https://app.adeo.no\n",
+			error:   "line 4: field ingress not found",
+		},
+		{
+			name:    "passwords are not accepted",
+			content: "deploy:\n  fasitUsername: srvapp\n  fasitPassword: hunter2\n",
+			error:   "field fasitPassword not found",
+		},
+		{
+			name:    "unknown key in an environment",
+			content: "environments:\n  p:\n    overrides:\n      namespaces: default\n",
+			error:   "field namespaces not found",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := tempDir(t, map[string]string{FileName: test.content})
+			defer os.RemoveAll(dir)
+
+			_, err := Load(filepath.Join(dir, FileName))
+			if err == nil || !strings.Contains(err.Error(), test.error) {
+				t.Errorf("expected an error containing '%s', got %v", test.error, err)
+			}
+		})
+	}
+
+	if _, err := Load(filepath.Join(os.TempDir(), "does-not-exist", FileName)); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		start string
+		found string
+	}{
+		{
+			name:  "in the start directory",
+			files: map[string]string{"repo/.git/": "", "repo/app/" + FileName: "", "repo/" + FileName: ""},
+			start: "repo/app",
+			found: "repo/app/" + FileName,
+		},
+		{
+			name:  "in a parent directory",
+			files: map[string]string{"repo/.git/": "", "repo/" + FileName: "", "repo/app/deploy/": ""},
+			start: "repo/app/deploy",
+			found: "repo/" + FileName,
+		},
+		{
+			name:  "not above the root of the repository",
+			files: map[string]string{FileName: "", "repo/.git/": "", "repo/app/": ""},
+			start: "repo/app",
+			found: "",
+		},
+		{
+			name:  "a .git file marks the root, as in worktrees",
+			files: map[string]string{FileName: "", "repo/.git": "gitdir: /elsewhere", "repo/app/": ""},
+			start: "repo/app",
+			found: "",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := tempDir(t, test.files)
+			defer os.RemoveAll(dir)
+
+			found, err := Discover(filepath.Join(dir, test.start))
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			expected := ""
+			if len(test.found) > 0 {
+				expected = filepath.Join(dir, test.found)
+			}
+			if found != expected {
+				t.Errorf("found '%s', want '%s'", found, expected)
+			}
+		})
+	}
+}
+
+func TestMergeMap(t *testing.T) {
+	a := map[string]string{"team": "myteam", "tier": "backend"}
+
+	if merged := MergeMap(nil, nil); merged != nil {
+		t.Errorf("merging empty maps gives %v, want nil", merged)
+	}
+	merged := MergeMap(a, map[string]string{"tier": "frontend"})
+	if !reflect.DeepEqual(merged, map[string]string{"team": "myteam", "tier": "frontend"}) {
+		t.Errorf("unexpected merged map %v", merged)
+	}
+	if a["tier"] != "backend" {
+		t.Error("merging modified the first map")
+	}
+}
@@ -5,12 +5,12 @@ import (
 	"fmt"
 	"github.com/Jeffail/gabs"
 	"github.com/nais/migrator/models/naisd"
+	"github.com/nais/migrator/report"
 	log "github.com/sirupsen/logrus"
 	"io/ioutil"
 	"net/http"
+	"path"
 	"strings"
-
-	"regexp"
 )
 
 const (
@@ -74,7 +74,7 @@ type FasitClientAdapter interface {
 	GetFasitEnvironmentClass(environmentName string) (string, error)
 	GetFasitApplication(application string) error
 	GetScopedResources(resourcesRequests []ResourceRequest, fasitEnvironment string, application string, zone string) (resources []NaisResource, err error)
-	getLoadBalancerConfig(application string, fasitEnvironment string) (*NaisResource, error)
+	getLoadBalancerConfig(application, fasitEnvironment, zone string) (*NaisResource, error)
 }
 
 type FasitResource struct {
@@ -134,17 +134,18 @@ func (nr NaisResource) ToResourceVariable(property string) string {
 	return strings.ToLower(normalizePropertyName(property))
 }
 
+// normalizePropertyName turns a property name into a valid environment variable name, consisting of letters, digits and
+// underscores and not starting with a digit. Other characters, including letters outside ASCII, become underscores.
 func normalizePropertyName(name string) string {
-	if strings.Contains(name, ".") {
-		name = strings.Replace(name, ".", "_", -1)
-	}
-
-	if strings.Contains(name, ":") {
-		name = strings.Replace(name, ":", "_", -1)
-	}
+	name = strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' {
+			return r
+		}
+		return '_'
+	}, name)
 
-	if strings.Contains(name, "-") {
-		name = strings.Replace(name, "-", "_", -1)
+	if len(name) > 0 && name[0] >= '0' && name[0] <= '9' {
+		name = "_" + name
 	}
 
 	return name
@@ -161,39 +162,39 @@ func (fasit FasitClient) GetScopedResources(resourcesRequests []ResourceRequest,
 	return resources, nil
 }
 
-func (fasit FasitClient) getLoadBalancerConfig(application string, fasitEnvironment string) (*NaisResource, error) {
+// getLoadBalancerConfig returns the load balancer configuration of an application in an environment and zone,
+// or nil if it has none. Entries that can not be used are returned as an error together with the usable ones.
+func (fasit FasitClient) getLoadBalancerConfig(application, fasitEnvironment, zone string) (*NaisResource, error) {
 	req, err := fasit.buildRequest("GET", "/api/v2/resources", map[string]string{
 		"environment": fasitEnvironment,
 		"application": application,
+		"zone":        zone,
 		"type":        "LoadBalancerConfig",
 	})
+	if err != nil {
+		return nil, err
+	}
 
 	body, appErr := fasit.doRequest(req)
 	if appErr != nil {
-		return nil, err
+		return nil, appErr
 	}
 
 	ingresses, err := parseLoadBalancerConfig(body)
-	if err != nil {
-		return nil, err
-	}
-
 	if len(ingresses) == 0 {
-		return nil, nil
+		return nil, err
 	}
 
 	return &NaisResource{
-		Name:         "",
-		Properties:   nil,
 		ResourceType: "LoadBalancerConfig",
-		Certificates: nil,
-		Secret:       nil,
 		Ingresses:    ingresses,
-	}, nil
-
+	}, err
 }
 
-func FetchFasitResources(fasit FasitClientAdapter, application string, fasitEnvironment string, zone string, usedResources []naisd.UsedResource) (naisresources []NaisResource, err error) {
+// FetchFasitResources fetches the resources used by an application, and its load balancer configuration.
+// Failing to fetch a used resource is an error. The load balancer configuration only adds ingresses,
+// so failing to fetch it is recorded in the report instead.
+func FetchFasitResources(fasit FasitClientAdapter, application string, fasitEnvironment string, zone string, usedResources []naisd.UsedResource, rep *report.Report) (naisresources []NaisResource, err error) {
 	resourceRequests := DefaultResourceRequests()
 
 	for _, resource := range usedResources {
@@ -209,16 +210,16 @@ func FetchFasitResources(fasit FasitClientAdapter, application string, fasitEnvi
 		return naisresources, err
 	}
 
-	if lbResource, e := fasit.getLoadBalancerConfig(application, fasitEnvironment); e == nil {
-		if lbResource != nil {
-			naisresources = append(naisresources, *lbResource)
-		}
-	} else {
-		log.Warningf("failed getting loadbalancer config for application %s in fasitEnvironment %s: %s ", application, fasitEnvironment, e)
+	lbResource, err := fasit.getLoadBalancerConfig(application, fasitEnvironment, zone)
+	if err != nil {
+		rep.Errorf("fasit:LoadBalancerConfig", "Load balancer configuration for environment '%s' zone '%s' could not be read, and its ingresses may be missing: %s",
+			fasitEnvironment, zone, err)
+	}
+	if lbResource != nil {
+		naisresources = append(naisresources, *lbResource)
 	}
 
 	return naisresources, nil
-
 }
 
 func (fasit FasitClient) doRequest(r *http.Request) ([]byte, naisd.AppError) {
@@ -237,11 +238,11 @@ func (fasit FasitClient) doRequest(r *http.Request) ([]byte, naisd.AppError) {
 	}
 
 	if resp.StatusCode == 404 {
-		return []byte{}, appError{nil, fmt.Sprintf("item not found in Fasit: %s", string(body)), http.StatusNotFound}
+		return []byte{}, appError{nil, fmt.Sprintf("item not found in Fasit: %s", strings.TrimSpace(string(body))), http.StatusNotFound}
 	}
 
 	if resp.StatusCode > 299 {
-		return []byte{}, appError{nil, fmt.Sprintf("error contacting Fasit: %s", string(body)), resp.StatusCode}
+		return []byte{}, appError{nil, fmt.Sprintf("error contacting Fasit: %s", strings.TrimSpace(string(body))), resp.StatusCode}
 	}
 
 	return body, nil
@@ -331,12 +332,7 @@ func (fasit FasitClient) mapToNaisResource(fasitResource FasitResource, property
 	resource.PropertyMap = propertyMap
 	resource.ID = fasitResource.Id
 	resource.Scope = fasitResource.Scope
-	resource.Secret = make(map[string]string)
-
-	if len(fasitResource.Secrets) > 0 {
-		k, v := vaultSecret(fasitResource.Secrets)
-		resource.Secret[k] = v
-	}
+	resource.Secret = vaultSecrets(fasitResource.Secrets)
 
 	if fasitResource.ResourceType == "certificate" && len(fasitResource.Certificates) > 0 {
 		files, err := resolveCertificates(fasitResource.Certificates)
@@ -348,19 +344,17 @@ func (fasit FasitClient) mapToNaisResource(fasitResource FasitResource, property
 		resource.Certificates = files
 
 	} else if fasitResource.ResourceType == "applicationproperties" {
-		lineFilter, err := regexp.Compile(`^[\p{L}\d_.]+=.+`)
+		properties, err := parseProperties(fasitResource.Properties["applicationProperties"])
 		if err != nil {
-			return NaisResource{}, fmt.Errorf("unable to compile regex: %s", err)
+			return NaisResource{}, fmt.Errorf("unable to parse application properties: %s", err)
 		}
 
-		for _, line := range strings.Split(fasitResource.Properties["applicationProperties"], "\n") {
-			line = strings.TrimSpace(line)
-			if lineFilter.MatchString(line) {
-				parts := strings.SplitN(line, "=", 2)
-				resource.Properties[parts[0]] = parts[1]
-			} else if len(line) > 0 {
-				log.Infof("the following string did not match our regex: %s", line)
+		for key, value := range properties {
+			if len(key) == 0 {
+				log.Warnf("skipping application property without a name in resource %s", fasitResource.Alias)
+				continue
 			}
+			resource.Properties[key] = value
 		}
 		delete(resource.Properties, "applicationProperties")
 	}
@@ -391,33 +385,47 @@ func resolveCertificates(files map[string]interface{}) (map[string][]byte, error
 
 }
 
+// parseLoadBalancerConfig returns the ingresses in a list of LoadBalancerConfig resources. An empty list has no ingresses.
+// Entries without a URL are skipped, and reported in the error together with the ingresses of the other entries.
 func parseLoadBalancerConfig(config []byte) ([]FasitIngress, error) {
 	jsn, err := gabs.ParseJSON(config)
 	if err != nil {
-		return nil, fmt.Errorf("error parsing load balancer config: %s ", config)
+		return nil, fmt.Errorf("error parsing load balancer config: %s", err)
 	}
 
-	ingresses := make([]FasitIngress, 0)
-	lbConfigs, _ := jsn.Children()
-	if len(lbConfigs) == 0 {
-		return nil, nil
+	lbConfigs, err := jsn.Children()
+	if err != nil {
+		return nil, fmt.Errorf("load balancer config is not a list: %s", config)
 	}
 
+	var ingresses []FasitIngress
+	var invalid []string
+
 	for _, lbConfig := range lbConfigs {
 		host, found := lbConfig.Path("properties.url").Data().(string)
-		if !found {
-			log.Warningf("no host found for loadbalancer config: %s", lbConfig)
+		if !found || len(strings.TrimSpace(host)) == 0 {
+			alias, _ := lbConfig.Path("alias").Data().(string)
+			invalid = append(invalid, fmt.Sprintf("'%s'", alias))
 			continue
 		}
 		pathList, _ := lbConfig.Path("properties.contextRoots").Data().(string)
-		paths := strings.Split(pathList, ",")
+		var paths []string
+		for _, path := range strings.Split(pathList, ",") {
+			if path = strings.TrimSpace(path); len(path) > 0 {
+				paths = append(paths, path)
+			}
+		}
+		// Without context roots, the whole host is served.
+		if len(paths) == 0 {
+			paths = []string{""}
+		}
 		for _, path := range paths {
-			ingresses = append(ingresses, FasitIngress{Host: host, Path: path})
+			ingresses = append(ingresses, FasitIngress{Host: strings.TrimSpace(host), Path: path})
 		}
 	}
 
-	if len(ingresses) == 0 {
-		return nil, fmt.Errorf("no loadbalancer config found for: %s", config)
+	if len(invalid) > 0 {
+		return ingresses, fmt.Errorf("load balancer config %s has no url", strings.Join(invalid, ", "))
 	}
 	return ingresses, nil
 }
@@ -441,26 +449,21 @@ func parseFilesObject(files map[string]interface{}) (fileName string, fileUrl st
 	return fileName, fileUrl, nil
 }
 
-func vaultSecret(secrets map[string]map[string]string) (string, string) {
-	key := getFirstKey(secrets)
-	vaultPath := secrets[key]["vaultpath"]
-	if len(vaultPath) == 0 {
-		return key, ""
-	}
-
-	parts := strings.Split(vaultPath, "/")
-	vaultPath = "/" + strings.Join(parts[:len(parts)-1], "/")
-
-	return key, vaultPath
-}
+// vaultSecrets returns the full Vault path of every secret in a resource, keyed by secret name.
+// The last path element is the key within the Vault KV secret. Secrets without a Vault path
+// are included with an empty path, so that they can be reported.
+func vaultSecrets(secrets map[string]map[string]string) map[string]string {
+	paths := make(map[string]string, len(secrets))
 
-func getFirstKey(m map[string]map[string]string) string {
-	if len(m) > 0 {
-		for key := range m {
-			return key
+	for key, secret := range secrets {
+		vaultPath := secret["vaultpath"]
+		if len(vaultPath) > 0 {
+			vaultPath = path.Clean("/" + vaultPath)
 		}
+		paths[key] = vaultPath
 	}
-	return ""
+
+	return paths
 }
 
 func (fasit FasitClient) buildRequest(method, path string, queryParams map[string]string) (*http.Request, error) {
@@ -0,0 +1,180 @@
+package fasit
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMapToNaisResourceSecrets(t *testing.T) {
+	fasitResource := FasitResource{
+		Alias:        "srvapp",
+		ResourceType: "credential",
+		Properties:   map[string]string{"username": "srvapp"},
+		Secrets: map[string]map[string]string{
+			"password":    {"ref": "https://fasit/api/v2/secrets/1", "vaultpath": "kv/preprod/fss/app/default/srvapp/password"},
+			"apikey":      {"ref": "https://fasit/api/v2/secrets/2", "vaultpath": "/kv/preprod/fss/app/default/api/apikey"},
+			"oldpassword": {"ref": "https://fasit/api/v2/secrets/3"},
+		},
+	}
+
+	expected := map[string]string{
+		"password":    "/kv/preprod/fss/app/default/srvapp/password",
+		"apikey":      "/kv/preprod/fss/app/default/api/apikey",
+		"oldpassword": "",
+	}
+
+	// Map iteration order is random; repeat to make sure no secret depends on it.
+	for i := 0; i < 20; i++ {
+		resource, err := FasitClient{}.mapToNaisResource(fasitResource, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !reflect.DeepEqual(resource.Secret, expected) {
+			t.Fatalf("secrets differ:\n got: %v\nwant: %v", resource.Secret, expected)
+		}
+	}
+}
+
+func TestParseLoadBalancerConfig(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    string
+		ingresses []FasitIngress
+		// error is part of the expected error, if any.
+		error string
+	}{
+		{
+			name:   "empty list",
+			config: `[]`,
+		},
+		{
+			name:      "context roots",
+			config:    `[{"alias": "lb", "properties": {"url": "app.adeo.no", "contextRoots": "app, /api"}}]`,
+			ingresses: []FasitIngress{{Host: "app.adeo.no", Path: "app"}, {Host: "app.adeo.no", Path: "/api"}},
+		},
+		{
+			name:      "trailing slashes are kept for the mapper to normalise",
+			config:    `[{"alias": "lb", "properties": {"url": "app.adeo.no", "contextRoots": "/app/"}}]`,
+			ingresses: []FasitIngress{{Host: "app.adeo.no", Path: "/app/"}},
+		},
+		{
+			name:      "without context roots the whole host is served",
+			config:    `[{"alias": "lb", "properties": {"url": " app.adeo.no "}}]`,
+			ingresses: []FasitIngress{{Host: "app.adeo.no", Path: ""}},
+		},
+		{
+			name:      "empty context roots",
+			config:    `[{"alias": "lb", "properties": {"url": "app.adeo.no", "contextRoots": " , "}}]`,
+			ingresses: []FasitIngress{{Host: "app.adeo.no", Path: ""}},
+		},
+		{
+			name: "entries without url",
+			config: `[{"alias": "lb-missing", "properties": {"contextRoots": "/app"}},
+				{"alias": "lb", "properties": {"url": "app.adeo.no", "contextRoots": "/app"}},
+				{"alias": "lb-blank", "properties": {"url": " "}}]`,
+			ingresses: []FasitIngress{{Host: "app.adeo.no", Path: "/app"}},
+			error:     "load balancer config 'lb-missing', 'lb-blank' has no url",
+		},
+		{
+			name:   "not a list",
+			config: `"lb"`,
+			error:  "load balancer config is not a list",
+		},
+		{
+			name:   "invalid json",
+			config: `[{"alias": `,
+			error:  "error parsing load balancer config",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ingresses, err := parseLoadBalancerConfig([]byte(test.config))
+
+			if len(test.error) > 0 {
+				if err == nil || !strings.Contains(err.Error(), test.error) {
+					t.Errorf("expected an error containing '%s', got %v", test.error, err)
+				}
+			} else if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !reflect.DeepEqual(ingresses, test.ingresses) {
+				t.Errorf("ingresses differ:\n got: %v\nwant: %v", ingresses, test.ingresses)
+			}
+		})
+	}
+}
+
+func TestGetLoadBalancerConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		body     string
+		resource *NaisResource
+		// error is part of the expected error, if any.
+		error string
+	}{
+		{
+			name:   "no load balancer config",
+			status: http.StatusOK,
+			body:   `[]`,
+		},
+		{
+			name:     "ingresses",
+			status:   http.StatusOK,
+			body:     `[{"alias": "lb", "properties": {"url": "app.adeo.no", "contextRoots": "/app"}}]`,
+			resource: &NaisResource{ResourceType: "LoadBalancerConfig", Ingresses: []FasitIngress{{Host: "app.adeo.no", Path: "/app"}}},
+		},
+		{
+			name:     "partial ingresses with an error",
+			status:   http.StatusOK,
+			body:     `[{"alias": "lb", "properties": {"url": "app.adeo.no"}}, {"alias": "lb-missing", "properties": {}}]`,
+			resource: &NaisResource{ResourceType: "LoadBalancerConfig", Ingresses: []FasitIngress{{Host: "app.adeo.no", Path: ""}}},
+			error:    "load balancer config 'lb-missing' has no url",
+		},
+		{
+			name:   "only entries without url",
+			status: http.StatusOK,
+			body:   `[{"alias": "lb-missing", "properties": {}}]`,
+			error:  "load balancer config 'lb-missing' has no url",
+		},
+		{
+			name:   "fasit failure",
+			status: http.StatusInternalServerError,
+			body:   "unavailable",
+			error:  "error contacting Fasit: unavailable",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				query := r.URL.Query()
+				if r.URL.Path != "/api/v2/resources" || query.Get("type") != "LoadBalancerConfig" ||
+					query.Get("application") != "app" || query.Get("environment") != "q1" || query.Get("zone") != "fss" {
+					t.Errorf("unexpected request %s", r.URL)
+				}
+				w.WriteHeader(test.status)
+				fmt.Fprint(w, test.body)
+			}))
+			defer server.Close()
+
+			resource, err := FasitClient{FasitUrl: server.URL}.getLoadBalancerConfig("app", "q1", "fss")
+
+			if len(test.error) > 0 {
+				if err == nil || !strings.Contains(err.Error(), test.error) {
+					t.Errorf("expected an error containing '%s', got %v", test.error, err)
+				}
+			} else if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !reflect.DeepEqual(resource, test.resource) {
+				t.Errorf("resource differs:\n got: %+v\nwant: %+v", resource, test.resource)
+			}
+		})
+	}
+}
@@ -0,0 +1,198 @@
+// Package fasittest provides an in-process fake Fasit server, serving the parts of the Fasit API used by the
+// migrator from fixture data. It is meant for tests that exercise the real Fasit client.
+package fasittest
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/nais/migrator/fasit"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+)
+
+// Fixtures is the data served by the fake Fasit server.
+type Fixtures struct {
+	// Environments maps Fasit environment names to their environment class.
+	Environments map[string]string `json:"environments"`
+	// Applications lists the applications known to Fasit.
+	Applications []string `json:"applications"`
+	// Resources are served by the scoped resource API. A resource matches a request by alias and type,
+	// and by environment and zone if they are set in its scope.
+	Resources []fasit.FasitResource `json:"resources"`
+	// LoadBalancerConfigs are served by the resource API when asked for LoadBalancerConfig resources.
+	LoadBalancerConfigs []LoadBalancerConfig `json:"loadBalancerConfigs"`
+	// Files are served under /files/, and hold the contents of certificates. A file reference starting with /
+	// in a resource is served as a URL on the fake server.
+	Files map[string]string `json:"files"`
+	// Failures maps API paths, such as /api/v2/resources, to a status code returned instead of the data.
+	Failures map[string]int `json:"failures"`
+}
+
+// LoadBalancerConfig is a load balancer configuration exposing an application on a host.
+type LoadBalancerConfig struct {
+	Alias        string `json:"alias"`
+	Application  string `json:"application"`
+	Environment  string `json:"environment"`
+	Zone         string `json:"zone"`
+	URL          string `json:"url"`
+	ContextRoots string `json:"contextRoots"`
+}
+
+// Load reads fixtures from a JSON file.
+func Load(path string) (Fixtures, error) {
+	var fixtures Fixtures
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fixtures, fmt.Errorf("read fixtures: %s", err)
+	}
+
+	err = json.Unmarshal(data, &fixtures)
+	if err != nil {
+		return fixtures, fmt.Errorf("decode fixtures in %s: %s", path, err)
+	}
+
+	return fixtures, nil
+}
+
+type server struct {
+	fixtures Fixtures
+	url      string
+}
+
+// NewServer starts a fake Fasit server serving the fixtures. The caller must close it.
+func NewServer(fixtures Fixtures) *httptest.Server {
+	s := &server{fixtures: fixtures}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v2/scopedresource", s.scopedResource)
+	mux.HandleFunc("/api/v2/resources", s.resources)
+	mux.HandleFunc("/api/v2/environments/", s.environment)
+	mux.HandleFunc("/api/v2/applications/", s.application)
+	mux.HandleFunc("/files/", s.file)
+
+	ts := httptest.NewServer(s.failures(mux))
+	s.url = ts.URL
+	return ts
+}
+
+// failures returns the configured status code for a path instead of serving it.
+func (s *server) failures(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if code, ok := s.fixtures.Failures[r.URL.Path]; ok {
+			http.Error(w, fmt.Sprintf("simulated failure for %s", r.URL.Path), code)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *server) scopedResource(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	for _, resource := range s.fixtures.Resources {
+		if resource.Alias != q.Get("alias") || !strings.EqualFold(resource.ResourceType, q.Get("type")) {
+			continue
+		}
+		if !matches(resource.Scope.Environment, q.Get("environment")) || !matches(resource.Scope.Zone, q.Get("zone")) {
+			continue
+		}
+		writeJSON(w, s.withFileURLs(resource))
+		return
+	}
+
+	http.Error(w, fmt.Sprintf("no resource with alias %s and type %s", q.Get("alias"), q.Get("type")), http.StatusNotFound)
+}
+
+func (s *server) resources(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	list := make([]map[string]interface{}, 0)
+
+	if q.Get("type") != "LoadBalancerConfig" {
+		writeJSON(w, list)
+		return
+	}
+
+	for _, lb := range s.fixtures.LoadBalancerConfigs {
+		if !matches(lb.Application, q.Get("application")) || !matches(lb.Environment, q.Get("environment")) || !matches(lb.Zone, q.Get("zone")) {
+			continue
+		}
+		properties := map[string]string{"contextRoots": lb.ContextRoots}
+		if len(lb.URL) > 0 {
+			properties["url"] = lb.URL
+		}
+		list = append(list, map[string]interface{}{
+			"alias":      lb.Alias,
+			"type":       "LoadBalancerConfig",
+			"scope":      fasit.Scope{Environment: lb.Environment, Zone: lb.Zone},
+			"properties": properties,
+		})
+	}
+
+	writeJSON(w, list)
+}
+
+func (s *server) environment(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/api/v2/environments/")
+	class, ok := s.fixtures.Environments[name]
+	if !ok {
+		http.Error(w, fmt.Sprintf("environment %s not found", name), http.StatusNotFound)
+		return
+	}
+	writeJSON(w, map[string]string{"name": name, "environmentclass": class})
+}
+
+func (s *server) application(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/api/v2/applications/")
+	for _, application := range s.fixtures.Applications {
+		if application == name {
+			writeJSON(w, map[string]string{"name": name})
+			return
+		}
+	}
+	http.Error(w, fmt.Sprintf("application %s not found", name), http.StatusNotFound)
+}
+
+func (s *server) file(w http.ResponseWriter, r *http.Request) {
+	content, ok := s.fixtures.Files[strings.TrimPrefix(r.URL.Path, "/files/")]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.Write([]byte(content))
+}
+
+// withFileURLs makes file references relative to the server absolute.
+func (s *server) withFileURLs(resource fasit.FasitResource) fasit.FasitResource {
+	files := make(map[string]interface{}, len(resource.Certificates))
+	for name, file := range resource.Certificates {
+		if object, ok := file.(map[string]interface{}); ok {
+			copied := make(map[string]interface{}, len(object))
+			for key, value := range object {
+				if ref, ok := value.(string); ok && key == "ref" && strings.HasPrefix(ref, "/") {
+					value = s.url + ref
+				}
+				copied[key] = value
+			}
+			file = copied
+		}
+		files[name] = file
+	}
+	resource.Certificates = files
+	return resource
+}
+
+// matches returns true if a fixture value is unset, or equal to the requested value.
+func matches(fixture, requested string) bool {
+	return len(fixture) == 0 || fixture == requested
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
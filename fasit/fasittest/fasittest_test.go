@@ -0,0 +1,248 @@
+package fasittest
+
+import (
+	"encoding/json"
+	"github.com/nais/migrator/fasit"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func fixtures() Fixtures {
+	return Fixtures{
+		Environments: map[string]string{"q1": "q"},
+		Applications: []string{"myapp"},
+		Resources: []fasit.FasitResource{
+			{Alias: "mydb", ResourceType: "DataSource", Scope: fasit.Scope{Environment: "q1", Zone: "fss"}, Properties: map[string]string{"url": "jdbc:q1-fss"}},
+			{Alias: "mydb", ResourceType: "DataSource", Scope: fasit.Scope{Environment: "q2"}, Properties: map[string]string{"url": "jdbc:q2"}},
+			{Alias: "mydb", ResourceType: "DataSource", Properties: map[string]string{"url": "jdbc:any"}},
+			{
+				Alias: "mycert", ResourceType: "Certificate",
+				Certificates: map[string]interface{}{
+					"keystore": map[string]interface{}{"ref": "/files/keystore", "filename": "keystore.jks"},
+					"external": map[string]interface{}{"ref": "https://fasit.example.com/files/1"},
+				},
+			},
+		},
+		LoadBalancerConfigs: []LoadBalancerConfig{
+			{Alias: "lb-q1", Application: "myapp", Environment: "q1", Zone: "fss", URL: "myapp-q1.example.com", ContextRoots: "/myapp"},
+			{Alias: "lb-q2", Application: "myapp", Environment: "q2", Zone: "fss", ContextRoots: "/myapp"},
+			{Alias: "lb-other", Application: "other", Environment: "q1", Zone: "fss", ContextRoots: "/other"},
+		},
+		Files: map[string]string{"keystore": "keystore contents"},
+	}
+}
+
+// get requests a path from the server, and returns the status code and body.
+func get(t *testing.T, base, path string, query url.Values) (int, []byte) {
+	u := base + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	resp, err := http.Get(u)
+	if err != nil {
+		t.Fatalf("get %s: %s", path, err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("read %s: %s", path, err)
+	}
+	return resp.StatusCode, body
+}
+
+func TestScopedResource(t *testing.T) {
+	server := NewServer(fixtures())
+	defer server.Close()
+
+	tests := []struct {
+		name  string
+		query url.Values
+		url   string
+	}{
+		{
+			name:  "environment and zone match the scope",
+			query: url.Values{"alias": {"mydb"}, "type": {"DataSource"}, "environment": {"q1"}, "zone": {"fss"}},
+			url:   "jdbc:q1-fss",
+		},
+		{
+			name:  "types are matched case-insensitively",
+			query: url.Values{"alias": {"mydb"}, "type": {"datasource"}, "environment": {"q1"}, "zone": {"fss"}},
+			url:   "jdbc:q1-fss",
+		},
+		{
+			name:  "a scope without a zone matches every zone",
+			query: url.Values{"alias": {"mydb"}, "type": {"DataSource"}, "environment": {"q2"}, "zone": {"sbs"}},
+			url:   "jdbc:q2",
+		},
+		{
+			name:  "a zone that differs from the scope falls through to the next resource",
+			query: url.Values{"alias": {"mydb"}, "type": {"DataSource"}, "environment": {"q1"}, "zone": {"sbs"}},
+			url:   "jdbc:any",
+		},
+		{
+			name:  "a resource without a scope matches every environment",
+			query: url.Values{"alias": {"mydb"}, "type": {"DataSource"}, "environment": {"p"}, "zone": {"fss"}},
+			url:   "jdbc:any",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			code, body := get(t, server.URL, "/api/v2/scopedresource", test.query)
+			if code != http.StatusOK {
+				t.Fatalf("status %d: %s", code, body)
+			}
+			var resource fasit.FasitResource
+			err := json.Unmarshal(body, &resource)
+			if err != nil {
+				t.Fatalf("decode resource: %s", err)
+			}
+			if resource.Properties["url"] != test.url {
+				t.Errorf("served the resource with url '%s', want '%s'", resource.Properties["url"], test.url)
+			}
+		})
+	}
+
+	for _, query := range []url.Values{
+		{"alias": {"otherdb"}, "type": {"DataSource"}, "environment": {"q1"}},
+		{"alias": {"mydb"}, "type": {"Credential"}, "environment": {"q1"}},
+	} {
+		if code, _ := get(t, server.URL, "/api/v2/scopedresource", query); code != http.StatusNotFound {
+			t.Errorf("%v: status %d, want %d", query, code, http.StatusNotFound)
+		}
+	}
+}
+
+func TestFileURLs(t *testing.T) {
+	server := NewServer(fixtures())
+	defer server.Close()
+
+	code, body := get(t, server.URL, "/api/v2/scopedresource", url.Values{"alias": {"mycert"}, "type": {"Certificate"}})
+	if code != http.StatusOK {
+		t.Fatalf("status %d: %s", code, body)
+	}
+	var resource fasit.FasitResource
+	err := json.Unmarshal(body, &resource)
+	if err != nil {
+		t.Fatalf("decode resource: %s", err)
+	}
+
+	keystore := resource.Certificates["keystore"].(map[string]interface{})
+	if keystore["ref"] != server.URL+"/files/keystore" || keystore["filename"] != "keystore.jks" {
+		t.Errorf("references on the server are made absolute, got %v", keystore)
+	}
+	external := resource.Certificates["external"].(map[string]interface{})
+	if external["ref"] != "https://fasit.example.com/files/1" {
+		t.Errorf("absolute references are kept, got %v", external)
+	}
+
+	code, body = get(t, server.URL, "/files/keystore", nil)
+	if code != http.StatusOK || string(body) != "keystore contents" {
+		t.Errorf("file served with status %d: %s", code, body)
+	}
+	if code, _ = get(t, server.URL, "/files/missing", nil); code != http.StatusNotFound {
+		t.Errorf("missing file served with status %d, want %d", code, http.StatusNotFound)
+	}
+}
+
+func TestLoadBalancerConfigs(t *testing.T) {
+	server := NewServer(fixtures())
+	defer server.Close()
+
+	aliases := func(query url.Values) []string {
+		code, body := get(t, server.URL, "/api/v2/resources", query)
+		if code != http.StatusOK {
+			t.Fatalf("status %d: %s", code, body)
+		}
+		var list []fasit.FasitResource
+		err := json.Unmarshal(body, &list)
+		if err != nil {
+			t.Fatalf("decode resources: %s", err)
+		}
+		names := make([]string, 0, len(list))
+		for _, resource := range list {
+			names = append(names, resource.Alias)
+		}
+		return names
+	}
+
+	tests := []struct {
+		query   url.Values
+		aliases []string
+	}{
+		{url.Values{"type": {"LoadBalancerConfig"}, "application": {"myapp"}, "environment": {"q1"}, "zone": {"fss"}}, []string{"lb-q1"}},
+		{url.Values{"type": {"LoadBalancerConfig"}, "application": {"myapp"}, "zone": {"fss"}}, []string{}},
+		{url.Values{"type": {"LoadBalancerConfig"}, "application": {"myapp"}, "environment": {"q2"}, "zone": {"fss"}}, []string{"lb-q2"}},
+		{url.Values{"type": {"DataSource"}, "application": {"myapp"}, "environment": {"q1"}}, []string{}},
+	}
+
+	for _, test := range tests {
+		if actual := aliases(test.query); !reflect.DeepEqual(actual, test.aliases) {
+			t.Errorf("%v: served %v, want %v", test.query, actual, test.aliases)
+		}
+	}
+
+	// Configurations without a URL are served without the property.
+	_, body := get(t, server.URL, "/api/v2/resources", tests[2].query)
+	var list []fasit.FasitResource
+	json.Unmarshal(body, &list)
+	if _, ok := list[0].Properties["url"]; ok || list[0].Properties["contextRoots"] != "/myapp" {
+		t.Errorf("unexpected properties %v", list[0].Properties)
+	}
+}
+
+func TestEnvironmentsAndApplications(t *testing.T) {
+	server := NewServer(fixtures())
+	defer server.Close()
+
+	code, body := get(t, server.URL, "/api/v2/environments/q1", nil)
+	var environment map[string]string
+	json.Unmarshal(body, &environment)
+	if code != http.StatusOK || environment["environmentclass"] != "q" {
+		t.Errorf("environment q1 served with status %d: %s", code, body)
+	}
+
+	tests := map[string]int{
+		"/api/v2/environments/p":        http.StatusNotFound,
+		"/api/v2/applications/myapp":    http.StatusOK,
+		"/api/v2/applications/otherapp": http.StatusNotFound,
+	}
+	for path, expected := range tests {
+		if code, _ := get(t, server.URL, path, nil); code != expected {
+			t.Errorf("%s: status %d, want %d", path, code, expected)
+		}
+	}
+}
+
+func TestFailures(t *testing.T) {
+	f := fixtures()
+	f.Failures = map[string]int{"/api/v2/resources": http.StatusInternalServerError}
+	server := NewServer(f)
+	defer server.Close()
+
+	query := url.Values{"type": {"LoadBalancerConfig"}, "application": {"myapp"}, "environment": {"q1"}, "zone": {"fss"}}
+	if code, _ := get(t, server.URL, "/api/v2/resources", query); code != http.StatusInternalServerError {
+		t.Errorf("failing path served with status %d, want %d", code, http.StatusInternalServerError)
+	}
+	// Only the configured path fails.
+	if code, _ := get(t, server.URL, "/api/v2/applications/myapp", nil); code != http.StatusOK {
+		t.Errorf("other paths served with status %d, want %d", code, http.StatusOK)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	loaded, err := Load("../../cmd/migrator/testdata/e2e/fasit.json")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(loaded.Resources) == 0 || len(loaded.Environments) == 0 {
+		t.Errorf("expected resources and environments, got %+v", loaded)
+	}
+
+	if _, err = Load("missing.json"); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
@@ -0,0 +1,181 @@
+package fasit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// parseProperties reads properties in the Java .properties format, following java.util.Properties.load:
+// lines ending in an odd number of backslashes continue on the next line, lines starting with # or ! are comments,
+// keys are separated from values by =, : or whitespace, and both may contain escapes such as \t, \: and \uXXXX.
+// Later properties replace earlier ones with the same key.
+func parseProperties(data string) (map[string]string, error) {
+	properties := make(map[string]string)
+
+	for i, line := range logicalLines(data) {
+		rawKey, rawValue := splitProperty(line)
+
+		key, err := unescapeProperty(rawKey)
+		if err != nil {
+			return nil, fmt.Errorf("property %d: key: %s", i+1, err)
+		}
+		value, err := unescapeProperty(rawValue)
+		if err != nil {
+			return nil, fmt.Errorf("property %d: value of '%s': %s", i+1, key, err)
+		}
+
+		properties[key] = value
+	}
+
+	return properties, nil
+}
+
+func isPropertyWhitespace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\f'
+}
+
+func trimPropertyWhitespace(s string) string {
+	return strings.TrimLeft(s, " \t\f")
+}
+
+// continues returns true if the line ends with an odd number of backslashes, which escapes the line terminator.
+func continues(line string) bool {
+	backslashes := len(line) - len(strings.TrimRight(line, `\`))
+	return backslashes%2 == 1
+}
+
+// logicalLines joins continued lines, and removes comments and blank lines.
+// Whitespace at the start of each line, including continuation lines, is removed.
+func logicalLines(data string) []string {
+	var lines []string
+	var current strings.Builder
+	continuation := false
+
+	natural := strings.Split(strings.Replace(strings.Replace(data, "\r\n", "\n", -1), "\r", "\n", -1), "\n")
+	for _, line := range natural {
+		line = trimPropertyWhitespace(line)
+
+		if !continuation {
+			if len(line) == 0 || line[0] == '#' || line[0] == '!' {
+				continue
+			}
+		} else if len(line) == 0 {
+			// An empty line ends a continued line.
+			lines = append(lines, current.String())
+			current.Reset()
+			continuation = false
+			continue
+		}
+
+		continuation = continues(line)
+		if continuation {
+			line = line[:len(line)-1]
+		}
+		current.WriteString(line)
+
+		if !continuation {
+			lines = append(lines, current.String())
+			current.Reset()
+		}
+	}
+
+	if continuation {
+		lines = append(lines, current.String())
+	}
+
+	return lines
+}
+
+// splitProperty splits a logical line into its key and value, both still escaped.
+// The key ends at the first unescaped =, : or whitespace. Whitespace around the separator is not part of the value.
+func splitProperty(line string) (string, string) {
+	keyEnd := len(line)
+	valueStart := len(line)
+	separator := false
+	escaped := false
+
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		if !escaped && (c == '=' || c == ':' || isPropertyWhitespace(c)) {
+			keyEnd = i
+			valueStart = i + 1
+			separator = c == '=' || c == ':'
+			break
+		}
+		escaped = c == '\\' && !escaped
+	}
+
+	for valueStart < len(line) {
+		c := line[valueStart]
+		if !isPropertyWhitespace(c) {
+			if separator || (c != '=' && c != ':') {
+				break
+			}
+			separator = true
+		}
+		valueStart++
+	}
+
+	return line[:keyEnd], line[valueStart:]
+}
+
+// unescapeProperty resolves escapes in a key or value. Unicode escapes are UTF-16 code units, so characters outside
+// the basic multilingual plane are written as a surrogate pair of escapes. A backslash before any other character is dropped.
+func unescapeProperty(s string) (string, error) {
+	if !strings.Contains(s, `\`) {
+		return s, nil
+	}
+
+	var units []uint16
+	var b strings.Builder
+
+	flush := func() {
+		if len(units) > 0 {
+			b.WriteString(string(utf16.Decode(units)))
+			units = units[:0]
+		}
+	}
+
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i == len(s)-1 {
+			flush()
+			b.WriteByte(s[i])
+			continue
+		}
+
+		i++
+		switch s[i] {
+		case 'u':
+			if len(s)-i-1 < 4 {
+				return "", fmt.Errorf("malformed \\uxxxx encoding in '%s'", s)
+			}
+			unit, err := strconv.ParseUint(s[i+1:i+5], 16, 16)
+			if err != nil {
+				return "", fmt.Errorf("malformed \\uxxxx encoding in '%s'", s)
+			}
+			units = append(units, uint16(unit))
+			i += 4
+			continue
+		case 't':
+			flush()
+			b.WriteByte('\t')
+		case 'n':
+			flush()
+			b.WriteByte('\n')
+		case 'r':
+			flush()
+			b.WriteByte('\r')
+		case 'f':
+			flush()
+			b.WriteByte('\f')
+		default:
+			flush()
+			b.WriteByte(s[i])
+		}
+	}
+	flush()
+
+	return b.String(), nil
+}
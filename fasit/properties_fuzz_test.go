@@ -0,0 +1,53 @@
+//go:build go1.18
+// +build go1.18
+
+package fasit
+
+import (
+	"reflect"
+	"testing"
+	"unicode/utf8"
+)
+
+func FuzzParseProperties(f *testing.F) {
+	f.Add("a=1\nb: 2\nc 3")
+	f.Add("# comment\n! comment\nlist=one,\\\n    two")
+	f.Add("key\\ with\\:escapes=\\u00e5\\uD83D\\uDE00\\t\\\\")
+	f.Add("a=1\\\n\nb=\\u12")
+	f.Add("a=1\r\nb=2\rc=3\\")
+
+	f.Fuzz(func(t *testing.T, data string) {
+		// Properties come from Fasit as JSON strings, which are always valid UTF-8.
+		if !utf8.ValidString(data) {
+			return
+		}
+		properties, err := parseProperties(data)
+		if err != nil {
+			return
+		}
+
+		parsed, err := parseProperties(formatProperties(properties))
+		if err != nil {
+			t.Fatalf("parse formatted properties: %s", err)
+		}
+		if !reflect.DeepEqual(parsed, properties) {
+			t.Fatalf("properties changed when written and read again:\n got: %q\nwant: %q", parsed, properties)
+		}
+	})
+}
+
+func FuzzNormalizePropertyName(f *testing.F) {
+	f.Add("foo.bar")
+	f.Add("1st-property")
+	f.Add("blåbær:øl")
+
+	f.Fuzz(func(t *testing.T, name string) {
+		normalized := normalizePropertyName(name)
+		if len(name) > 0 && !validEnvName.MatchString(normalized) {
+			t.Fatalf("normalizePropertyName(%q) = %q is not a valid environment variable name", name, normalized)
+		}
+		if normalizePropertyName(normalized) != normalized {
+			t.Fatalf("normalizePropertyName is not idempotent for %q", name)
+		}
+	})
+}
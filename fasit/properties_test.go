@@ -0,0 +1,250 @@
+package fasit
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"testing/quick"
+	"unicode/utf16"
+)
+
+func TestParseProperties(t *testing.T) {
+	tests := []struct {
+		name       string
+		data       string
+		properties map[string]string
+	}{
+		{
+			name:       "separators",
+			data:       "a=1\nb:2\nc 3\nd = 4\ne : 5\nf\t=\t6\ng 7 8",
+			properties: map[string]string{"a": "1", "b": "2", "c": "3", "d": "4", "e": "5", "f": "6", "g": "7 8"},
+		},
+		{
+			name:       "only the first separator counts",
+			data:       "url=https://host:8443/path?a=b\nratio: 1:2",
+			properties: map[string]string{"url": "https://host:8443/path?a=b", "ratio": "1:2"},
+		},
+		{
+			name:       "keys with dashes and colons",
+			data:       "cache-ttl=3600\nno.nav\\:port=8080",
+			properties: map[string]string{"cache-ttl": "3600", "no.nav:port": "8080"},
+		},
+		{
+			name:       "comments and blank lines",
+			data:       "# comment\n! also a comment\n\n   \n  #indented comment\na=1 # not a comment",
+			properties: map[string]string{"a": "1 # not a comment"},
+		},
+		{
+			name:       "empty values and keys without values",
+			data:       "empty=\nbare\nspaced =   ",
+			properties: map[string]string{"empty": "", "bare": "", "spaced": ""},
+		},
+		{
+			name:       "trailing whitespace in values is kept",
+			data:       "a=1  ",
+			properties: map[string]string{"a": "1  "},
+		},
+		{
+			name:       "continuation lines",
+			data:       "list=one,\\\n     two,\\\n     three\nnext=1",
+			properties: map[string]string{"list": "one,two,three", "next": "1"},
+		},
+		{
+			name:       "a continued line starting with # is not a comment",
+			data:       "a=1\\\n#2",
+			properties: map[string]string{"a": "1#2"},
+		},
+		{
+			name:       "an even number of backslashes does not continue",
+			data:       "path=C:\\\\\nnext=1",
+			properties: map[string]string{"path": "C:\\", "next": "1"},
+		},
+		{
+			name:       "a blank line ends a continued line",
+			data:       "a=1\\\n\nb=2",
+			properties: map[string]string{"a": "1", "b": "2"},
+		},
+		{
+			name:       "continuation at the end of the data",
+			data:       "a=1\\",
+			properties: map[string]string{"a": "1"},
+		},
+		{
+			name:       "comments are not continued",
+			data:       "# comment\\\na=1",
+			properties: map[string]string{"a": "1"},
+		},
+		{
+			name:       "line terminators",
+			data:       "a=1\r\nb=2\rc=3\n",
+			properties: map[string]string{"a": "1", "b": "2", "c": "3"},
+		},
+		{
+			name:       "escapes",
+			data:       "tab=a\\tb\nnewline=a\\nb\nother=\\q\\=\\:\\\\\nkey\\ with\\ spaces=1\n\\#hash=2",
+			properties: map[string]string{"tab": "a\tb", "newline": "a\nb", "other": "q=:\\", "key with spaces": "1", "#hash": "2"},
+		},
+		{
+			name:       "unicode escapes",
+			data:       "name=Bl\\u00e5b\\u00e6r\nemoji=\\uD83D\\uDE00\n\\u00f8l=1\nutf8=blåbær",
+			properties: map[string]string{"name": "Blåbær", "emoji": "😀", "øl": "1", "utf8": "blåbær"},
+		},
+		{
+			name:       "later properties win",
+			data:       "a=1\na=2",
+			properties: map[string]string{"a": "2"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			properties, err := parseProperties(test.data)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !reflect.DeepEqual(properties, test.properties) {
+				t.Fatalf("properties differ:\n got: %q\nwant: %q", properties, test.properties)
+			}
+		})
+	}
+}
+
+func TestParsePropertiesMalformedUnicode(t *testing.T) {
+	for _, data := range []string{"a=\\u12", "a=\\u12g4", "\\uXYZW=1"} {
+		_, err := parseProperties(data)
+		if err == nil {
+			t.Errorf("expected an error for %q", data)
+		}
+	}
+}
+
+func TestMapToNaisResourceApplicationProperties(t *testing.T) {
+	fasitResource := FasitResource{
+		Alias:        "app",
+		ResourceType: "applicationproperties",
+		Properties: map[string]string{
+			"applicationProperties": "# settings\nfoo.bar=1\ncache-ttl: 3600\nlist=a,\\\n  b\n=no name\n",
+		},
+	}
+
+	resource, err := FasitClient{}.mapToNaisResource(fasitResource, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := map[string]string{"foo.bar": "1", "cache-ttl": "3600", "list": "a,b"}
+	if !reflect.DeepEqual(resource.Properties, expected) {
+		t.Fatalf("properties differ:\n got: %q\nwant: %q", resource.Properties, expected)
+	}
+}
+
+// formatProperties writes properties in the .properties format, escaping everything that parseProperties
+// would otherwise interpret, and writing characters outside printable ASCII as unicode escapes.
+func formatProperties(properties map[string]string) string {
+	escape := func(s string) string {
+		var b strings.Builder
+		for _, r := range s {
+			switch {
+			case r == '\t':
+				b.WriteString(`\t`)
+			case r == '\n':
+				b.WriteString(`\n`)
+			case r == '\r':
+				b.WriteString(`\r`)
+			case r == '\f':
+				b.WriteString(`\f`)
+			case strings.ContainsRune(`\=:#! `, r):
+				b.WriteByte('\\')
+				b.WriteRune(r)
+			case r < 0x20 || r > 0x7e:
+				for _, unit := range utf16.Encode([]rune{r}) {
+					fmt.Fprintf(&b, `\u%04x`, unit)
+				}
+			default:
+				b.WriteRune(r)
+			}
+		}
+		return b.String()
+	}
+
+	keys := make([]string, 0, len(properties))
+	for key := range properties {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, key := range keys {
+		fmt.Fprintf(&b, "%s=%s\n", escape(key), escape(properties[key]))
+	}
+	return b.String()
+}
+
+// Any properties written in the .properties format are read back unchanged.
+func TestParsePropertiesRoundTrip(t *testing.T) {
+	roundTrip := func(properties map[string]string) bool {
+		parsed, err := parseProperties(formatProperties(properties))
+		return err == nil && reflect.DeepEqual(parsed, properties)
+	}
+
+	err := quick.Check(roundTrip, &quick.Config{MaxCount: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+var validEnvName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Any property name becomes a valid environment variable name, with one character for each character in the name.
+func TestNormalizePropertyNameIsValid(t *testing.T) {
+	valid := func(name string) bool {
+		if len(name) == 0 {
+			return normalizePropertyName(name) == ""
+		}
+		normalized := normalizePropertyName(name)
+		length := len([]rune(name))
+		if name[0] >= '0' && name[0] <= '9' {
+			length++
+		}
+		return validEnvName.MatchString(normalized) && len(normalized) == length
+	}
+
+	err := quick.Check(valid, &quick.Config{MaxCount: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Normalizing a normalized name does not change it.
+func TestNormalizePropertyNameIsIdempotent(t *testing.T) {
+	idempotent := func(name string) bool {
+		normalized := normalizePropertyName(name)
+		return normalizePropertyName(normalized) == normalized
+	}
+
+	err := quick.Check(idempotent, &quick.Config{MaxCount: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNormalizePropertyName(t *testing.T) {
+	tests := map[string]string{
+		"foo.bar":           "foo_bar",
+		"no.nav:port":       "no_nav_port",
+		"cache-ttl":         "cache_ttl",
+		"ALREADY_VALID_123": "ALREADY_VALID_123",
+		"1st.property":      "_1st_property",
+		"blåbær":            "bl_b_r",
+		"with space/slash":  "with_space_slash",
+	}
+
+	for name, expected := range tests {
+		if normalized := normalizePropertyName(name); normalized != expected {
+			t.Errorf("normalizePropertyName(%q) = %q, want %q", name, normalized, expected)
+		}
+	}
+}
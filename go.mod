@@ -2,6 +2,8 @@ module github.com/nais/migrator
 
 require (
 	github.com/Jeffail/gabs v1.4.0
+	github.com/evanphx/json-patch/v5 v5.6.0
+	github.com/pkg/errors v0.9.1 // indirect
 	github.com/sirupsen/logrus v1.4.2
 	github.com/spf13/pflag v1.0.5
 	gopkg.in/yaml.v2 v2.2.1
@@ -0,0 +1,102 @@
+package input
+
+import (
+	"fmt"
+	"github.com/nais/migrator/models/naisd"
+	"github.com/nais/migrator/report"
+	"gopkg.in/yaml.v2"
+	"sort"
+	"strings"
+)
+
+// defaulted lists the manifest fields naisd has defaults for, by their name in the manifest.
+var defaulted = []string{"image", "port", "healthcheck", "prometheus", "replicas", "resources", "deploymentstrategy"}
+
+// Decode reads a naisd manifest on top of the defaults naisd applied, so that the result describes
+// what actually ran in the cluster.
+//
+// Fields that are not part of the naisd manifest, often misspellings, are errors in strict mode.
+// Otherwise they are reported and ignored, as naisd did.
+func Decode(data []byte, application string, strict bool) (naisd.NaisManifest, report.Report, error) {
+	var rep report.Report
+	manifest := naisd.DefaultManifest(application)
+
+	err := yaml.UnmarshalStrict(data, &manifest)
+	unknown, other := unknownFields(err)
+
+	if len(other) > 0 {
+		return manifest, rep, fmt.Errorf("decode manifest: %s", strings.Join(other, "; "))
+	}
+
+	if len(unknown) > 0 {
+		if strict {
+			return manifest, rep, fmt.Errorf("manifest contains unknown fields: %s", strings.Join(unknown, "; "))
+		}
+
+		manifest = naisd.DefaultManifest(application)
+		err = yaml.Unmarshal(data, &manifest)
+		if err != nil {
+			return manifest, rep, fmt.Errorf("decode manifest: %s", err)
+		}
+		for _, field := range unknown {
+			rep.Warnf("manifest", "Ignoring unknown field: %s", field)
+		}
+	}
+
+	present, err := topLevelFields(data)
+	if err != nil {
+		return manifest, rep, fmt.Errorf("decode manifest: %s", err)
+	}
+
+	var applied []string
+	for _, field := range defaulted {
+		if !present[field] {
+			applied = append(applied, field)
+		}
+	}
+	if len(applied) > 0 {
+		rep.Infof("manifest", "Using naisd defaults for fields not in the manifest: %s", strings.Join(applied, ", "))
+	}
+
+	return manifest, rep, nil
+}
+
+// unknownFields splits decoding errors into unknown field errors and all other errors.
+func unknownFields(err error) ([]string, []string) {
+	if err == nil {
+		return nil, nil
+	}
+
+	typeError, ok := err.(*yaml.TypeError)
+	if !ok {
+		return nil, []string{err.Error()}
+	}
+
+	var unknown, other []string
+	for _, message := range typeError.Errors {
+		if strings.Contains(message, "not found in type") {
+			unknown = append(unknown, message)
+		} else {
+			other = append(other, message)
+		}
+	}
+	sort.Strings(unknown)
+
+	return unknown, other
+}
+
+func topLevelFields(data []byte) (map[string]bool, error) {
+	var fields map[string]interface{}
+
+	err := yaml.Unmarshal(data, &fields)
+	if err != nil {
+		return nil, err
+	}
+
+	present := make(map[string]bool, len(fields))
+	for key := range fields {
+		present[strings.ToLower(key)] = true
+	}
+
+	return present, nil
+}
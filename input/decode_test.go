@@ -0,0 +1,110 @@
+package input
+
+import (
+	"github.com/nais/migrator/models/naisd"
+	"github.com/nais/migrator/report"
+	"strings"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest string
+		strict   bool
+		// error is part of the expected error, if decoding fails.
+		error    string
+		findings []report.Finding
+		check    func(t *testing.T, manifest naisd.NaisManifest)
+	}{
+		{
+			name:     "all defaulted fields present",
+			manifest: "image: repo/app\nport: 8080\nhealthcheck: {}\nprometheus: {}\nreplicas: {}\nresources: {}\ndeploymentstrategy: Recreate\n",
+			strict:   true,
+			check: func(t *testing.T, manifest naisd.NaisManifest) {
+				if manifest.Image != "repo/app" || manifest.DeploymentStrategy != "Recreate" {
+					t.Errorf("unexpected manifest %+v", manifest)
+				}
+			},
+		},
+		{
+			name:     "defaulted fields are reported",
+			manifest: "image: repo/app\nport: 8080\n",
+			strict:   true,
+			findings: []report.Finding{
+				{Severity: report.Info, Source: "manifest", Message: "Using naisd defaults for fields not in the manifest: healthcheck, prometheus, replicas, resources, deploymentstrategy"},
+			},
+			check: func(t *testing.T, manifest naisd.NaisManifest) {
+				defaults := naisd.DefaultManifest("app")
+				if manifest.Replicas != defaults.Replicas {
+					t.Errorf("replicas are %+v, want the defaults %+v", manifest.Replicas, defaults.Replicas)
+				}
+			},
+		},
+		{
+			name:     "strict mode rejects unknown fields with their line",
+			manifest: "image: repo/app\nport: 8080\nhealtcheck:\n  liveness:\n    path: isalive\n",
+			strict:   true,
+			error:    "manifest contains unknown fields: line 3: field healtcheck not found",
+		},
+		{
+			name:     "all unknown fields are listed",
+			manifest: "image: repo/app\nreplica: {}\nhealthcheck:\n  liveness:\n    pathh: isalive\n",
+			strict:   true,
+			error:    "line 2: field replica not found in type naisd.NaisManifest; line 5: field pathh not found",
+		},
+		{
+			name:     "unknown fields are ignored with a warning when allowed",
+			manifest: "image: repo/app\nport: 8080\nhealthcheck: {}\nprometheus: {}\nreplicas: {}\nresources: {}\ndeploymentstrategy: Recreate\nteamm: myteam\n",
+			strict:   false,
+			findings: []report.Finding{
+				{Severity: report.Warning, Source: "manifest", Message: "Ignoring unknown field: line 8: field teamm not found in type naisd.NaisManifest"},
+			},
+			check: func(t *testing.T, manifest naisd.NaisManifest) {
+				if manifest.Image != "repo/app" || manifest.Port != 8080 {
+					t.Errorf("known fields were not decoded: %+v", manifest)
+				}
+			},
+		},
+		{
+			name:     "other errors fail even when unknown fields are allowed",
+			manifest: "image: repo/app\nport: http\n",
+			strict:   false,
+			error:    "decode manifest: line 2: cannot unmarshal",
+		},
+		{
+			name:     "invalid yaml",
+			manifest: "image: [repo/app\n",
+			strict:   false,
+			error:    "decode manifest:",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			manifest, rep, err := Decode([]byte(test.manifest), "app", test.strict)
+
+			if len(test.error) > 0 {
+				if err == nil || !strings.Contains(err.Error(), test.error) {
+					t.Fatalf("expected an error containing '%s', got %v", test.error, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if len(rep.Findings) != len(test.findings) {
+				t.Fatalf("got findings %+v, want %+v", rep.Findings, test.findings)
+			}
+			for i := range test.findings {
+				if rep.Findings[i] != test.findings[i] {
+					t.Errorf("finding differs:\n got: %+v\nwant: %+v", rep.Findings[i], test.findings[i])
+				}
+			}
+			if test.check != nil {
+				test.check(t, manifest)
+			}
+		})
+	}
+}
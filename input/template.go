@@ -0,0 +1,218 @@
+// Package input reads naisd manifests, rendering templates before they are decoded.
+package input
+
+import (
+	"bytes"
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+// Preserved placeholders are replaced by numbers, which decode into both text and number fields,
+// so that a placeholder can also be used for fields such as the port.
+const placeholderFormat = "7531%05d"
+
+var (
+	action      = regexp.MustCompile(`\{\{-?\s*(.*?)\s*-?\}\}`)
+	variable    = regexp.MustCompile(`^\.?[A-Za-z_][\w-]*(\.[A-Za-z_][\w-]*)*$`)
+	placeholder = regexp.MustCompile(`\b7531\d{5}\b`)
+)
+
+// keywords are bare words with a meaning in Go templates, which are never taken for variables.
+var keywords = map[string]bool{
+	"if": true, "else": true, "end": true, "range": true, "with": true, "define": true, "template": true,
+	"block": true, "break": true, "continue": true, "nil": true, "true": true, "false": true,
+}
+
+// Values are the variables available to manifest templates.
+type Values map[string]interface{}
+
+// LoadValues reads template variables from a YAML file.
+func LoadValues(path string) (Values, error) {
+	var values map[interface{}]interface{}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read values: %s", err)
+	}
+
+	err = yaml.Unmarshal(data, &values)
+	if err != nil {
+		return nil, fmt.Errorf("decode values %s: %s", path, err)
+	}
+
+	return normalize(values).(map[string]interface{}), nil
+}
+
+// Set assigns a value from a key=value expression. Dotted keys assign nested values.
+func (v Values) Set(assignment string) error {
+	parts := strings.SplitN(assignment, "=", 2)
+	if len(parts) != 2 || len(parts[0]) == 0 {
+		return fmt.Errorf("'%s' is not on the form key=value", assignment)
+	}
+
+	keys := strings.Split(parts[0], ".")
+	current := map[string]interface{}(v)
+	for _, key := range keys[:len(keys)-1] {
+		next, ok := current[key].(map[string]interface{})
+		if !ok {
+			next = make(map[string]interface{})
+			current[key] = next
+		}
+		current = next
+	}
+	current[keys[len(keys)-1]] = parts[1]
+
+	return nil
+}
+
+func (v Values) lookup(keys []string) (interface{}, error) {
+	var current interface{} = map[string]interface{}(v)
+
+	for i, key := range keys {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("'%s' is not a map", strings.Join(keys[:i], "."))
+		}
+		current, ok = m[key]
+		if !ok {
+			return nil, fmt.Errorf("no value given for '%s'", strings.Join(keys, "."))
+		}
+	}
+
+	return current, nil
+}
+
+// Placeholders are template actions that were kept out of rendering, to be restored in the output.
+type Placeholders map[string]string
+
+// Restore replaces preserved placeholders in the output with their original template actions.
+func (p Placeholders) Restore(data []byte) []byte {
+	if len(p) == 0 {
+		return data
+	}
+	return placeholder.ReplaceAllFunc(data, func(token []byte) []byte {
+		if original, ok := p[string(token)]; ok {
+			return []byte(original)
+		}
+		return token
+	})
+}
+
+// Render renders a naisd manifest template using Go template syntax.
+//
+// Both `{{ .version }}` and `{{ .Values.version }}` refer to the value `version`. Mustache style placeholders
+// such as `{{version}}` are also understood. Placeholders for variables named in preserve are not rendered,
+// but replaced by tokens that can be restored in the output using the returned Placeholders.
+//
+// Only variables are rendered. Other actions, such as `{{ $labels.app }}` in alert descriptions, are kept as they are.
+func Render(data []byte, values Values, preserve []string) ([]byte, Placeholders, error) {
+	if !bytes.Contains(data, []byte("{{")) {
+		return data, nil, nil
+	}
+
+	placeholders := make(Placeholders)
+	keep := make(map[string]bool, len(preserve))
+	for _, name := range preserve {
+		keep[name] = true
+	}
+
+	source := replaceActions(string(data), func(match string) string {
+		inner := action.FindStringSubmatch(match)[1]
+		if !variable.MatchString(inner) || keywords[inner] {
+			return literal(match)
+		}
+
+		name := strings.TrimPrefix(strings.TrimPrefix(inner, "."), "Values.")
+		if keep[name] {
+			token := fmt.Sprintf(placeholderFormat, len(placeholders))
+			placeholders[token] = match
+			return token
+		}
+
+		// Rewrite variables into lookups, as names may contain characters not allowed in field names.
+		expression := "value"
+		for _, key := range strings.Split(name, ".") {
+			expression += fmt.Sprintf(" %q", key)
+		}
+		left, right := "{{ ", " }}"
+		if strings.HasPrefix(match, "{{-") {
+			left = "{{- "
+		}
+		if strings.HasSuffix(match, "-}}") {
+			right = " -}}"
+		}
+		return left + expression + right
+	})
+
+	funcs := template.FuncMap{
+		"value": func(keys ...string) (interface{}, error) {
+			return values.lookup(keys)
+		},
+	}
+
+	tmpl, err := template.New("manifest").Option("missingkey=error").Funcs(funcs).Parse(source)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parse template: %s", err)
+	}
+
+	context := make(map[string]interface{}, len(values)+1)
+	for k, v := range values {
+		context[k] = v
+	}
+	context["Values"] = map[string]interface{}(values)
+
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, context)
+	if err != nil {
+		return nil, nil, fmt.Errorf("render template: %s", err)
+	}
+
+	return buf.Bytes(), placeholders, nil
+}
+
+// replaceActions replaces each template action in source using replace. Delimiters outside of actions are
+// escaped, so that they are kept as text.
+func replaceActions(source string, replace func(string) string) string {
+	var b strings.Builder
+	last := 0
+
+	for _, match := range action.FindAllStringIndex(source, -1) {
+		b.WriteString(escapeDelimiters(source[last:match[0]]))
+		b.WriteString(replace(source[match[0]:match[1]]))
+		last = match[1]
+	}
+	b.WriteString(escapeDelimiters(source[last:]))
+
+	return b.String()
+}
+
+func escapeDelimiters(text string) string {
+	return strings.Replace(text, "{{", literal("{{"), -1)
+}
+
+// literal returns an action that renders text as it is.
+func literal(text string) string {
+	return fmt.Sprintf("{{ %q }}", text)
+}
+
+func normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, val := range v {
+			m[fmt.Sprintf("%v", key)] = normalize(val)
+		}
+		return m
+	case []interface{}:
+		for i, val := range v {
+			v[i] = normalize(val)
+		}
+		return v
+	default:
+		return value
+	}
+}
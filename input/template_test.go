@@ -0,0 +1,132 @@
+package input
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	values := Values{
+		"version": "1.2.3",
+		"team":    "myteam",
+		"cluster": map[string]interface{}{"name": "dev-fss"},
+	}
+
+	tests := []struct {
+		name     string
+		template string
+		rendered string
+	}{
+		{
+			name:     "no actions",
+			template: "image: repo/app:1\n",
+			rendered: "image: repo/app:1\n",
+		},
+		{
+			name:     "variables",
+			template: "image: repo/app:{{ .version }}\nteam: {{ .Values.team }}\n",
+			rendered: "image: repo/app:1.2.3\nteam: myteam\n",
+		},
+		{
+			name:     "mustache placeholders",
+			template: "image: repo/app:{{version}}\nteam: {{team}}\n",
+			rendered: "image: repo/app:1.2.3\nteam: myteam\n",
+		},
+		{
+			name:     "nested values",
+			template: "ingress: https://app.{{ cluster.name }}.example\n",
+			rendered: "ingress: https://app.dev-fss.example\n",
+		},
+		{
+			name:     "trim markers",
+			template: "image: repo/app:\n  {{- .version }}\n",
+			rendered: "image: repo/app:1.2.3\n",
+		},
+		{
+			name: "alert annotations are kept",
+			template: "image: repo/app:{{ version }}\nalerts:\n- alert: down\n" +
+				"  annotations:\n    description: \"{{ $labels.app }} is down in {{ $labels.namespace }}\"\n" +
+				"    action: \"{{ if gt $value 1.0 }}page{{ end }}\"\n",
+			rendered: "image: repo/app:1.2.3\nalerts:\n- alert: down\n" +
+				"  annotations:\n    description: \"{{ $labels.app }} is down in {{ $labels.namespace }}\"\n" +
+				"    action: \"{{ if gt $value 1.0 }}page{{ end }}\"\n",
+		},
+		{
+			name:     "unclosed delimiters are kept",
+			template: "description: \"{{ not a template\"\nimage: repo/app:{{ version }}\n",
+			rendered: "description: \"{{ not a template\"\nimage: repo/app:1.2.3\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rendered, placeholders, err := Render([]byte(test.template), values, nil)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if string(rendered) != test.rendered {
+				t.Fatalf("rendered template differs:\n got: %q\nwant: %q", rendered, test.rendered)
+			}
+			if len(placeholders) > 0 {
+				t.Fatalf("unexpected placeholders: %v", placeholders)
+			}
+		})
+	}
+}
+
+func TestRenderMissingValue(t *testing.T) {
+	tests := map[string]string{
+		"image: repo/app:{{ .version }}":         "no value given for 'version'",
+		"image: repo/app:{{ .Values.version }}":  "no value given for 'version'",
+		"image: repo/app:{{version}}":            "no value given for 'version'",
+		"ingress: https://{{ cluster.name }}.no": "no value given for 'cluster.name'",
+		"ingress: https://{{ team.name }}.no":    "'team' is not a map",
+	}
+
+	for template, expected := range tests {
+		_, _, err := Render([]byte(template), Values{"team": "myteam"}, nil)
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("%s: expected an error containing '%s', got %v", template, expected, err)
+		}
+	}
+}
+
+func TestRenderPreserve(t *testing.T) {
+	template := "image: repo/app:{{ version }}\nport: {{ .Values.port }}\nteam: {{ team }}\n"
+
+	rendered, placeholders, err := Render([]byte(template), Values{"team": "myteam"}, []string{"version", "port"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(placeholders) != 2 {
+		t.Fatalf("expected 2 placeholders, got %v", placeholders)
+	}
+
+	// Preserved placeholders must survive strict decoding, also in number fields.
+	manifest, _, err := Decode(rendered, "app", true)
+	if err != nil {
+		t.Fatalf("decode rendered manifest: %s", err)
+	}
+	if manifest.Team != "myteam" {
+		t.Fatalf("team is '%s', want 'myteam'", manifest.Team)
+	}
+
+	output := fmt.Sprintf("image: %s\nport: %d\n", manifest.Image, manifest.Port)
+	restored := string(placeholders.Restore([]byte(output)))
+	expected := "image: repo/app:{{ version }}\nport: {{ .Values.port }}\n"
+	if restored != expected {
+		t.Fatalf("restored output differs:\n got: %q\nwant: %q", restored, expected)
+	}
+}
+
+func TestRestoreLeavesOtherNumbers(t *testing.T) {
+	placeholders := Placeholders{"753100000": "{{ version }}"}
+
+	output := "image: app:753100000\nother: 1753100000\nunknown: 753100001\n"
+	restored := string(placeholders.Restore([]byte(output)))
+	expected := "image: app:{{ version }}\nother: 1753100000\nunknown: 753100001\n"
+	if restored != expected {
+		t.Fatalf("restored output differs:\n got: %q\nwant: %q", restored, expected)
+	}
+}
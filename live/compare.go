@@ -0,0 +1,298 @@
+package live
+
+import (
+	"fmt"
+	"github.com/nais/migrator/cluster"
+	"github.com/nais/migrator/models/kubernetes"
+	"github.com/nais/migrator/models/naiserator"
+	"github.com/nais/migrator/redact"
+	"github.com/nais/migrator/render"
+	"github.com/nais/migrator/report"
+	"sort"
+	"strconv"
+)
+
+// serviceAccountMount is mounted into every pod by Kubernetes, and is not part of the application.
+const serviceAccountMount = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// Compare renders the migrated application into the objects Naiserator would create, and reports how they differ
+// from the live objects naisd created. Environment variables and mounts that exist in the live pod but not in the
+// migrated application are warnings, as the application is likely to depend on them. Values of environment variables
+// that the classifier considers sensitive are never shown.
+func Compare(objects Objects, app naiserator.Application, target cluster.Cluster, classifier redact.Classifier) report.Report {
+	var rep report.Report
+	var migrated kubernetes.Deployment
+	var service kubernetes.Service
+	var ingress kubernetes.Ingress
+
+	rendered, _ := render.Kubernetes(app, target)
+	for _, object := range rendered {
+		switch o := object.(type) {
+		case kubernetes.Deployment:
+			migrated = o
+		case kubernetes.Service:
+			service = o
+		case kubernetes.Ingress:
+			ingress = o
+		}
+	}
+
+	deployment, ok := objects.deployment(app.Name)
+	if ok {
+		compareDeployment(objects, deployment, migrated, classifier, &rep)
+	} else {
+		rep.Warnf("live", "There is no live Deployment named '%s' to compare with.", app.Name)
+	}
+
+	for _, live := range objects.Services {
+		if live.Name == app.Name {
+			compareService(live, service, &rep)
+		}
+	}
+
+	compareIngresses(objects.Ingresses, ingress, &rep)
+
+	return rep
+}
+
+// deployment returns the deployment with the given name, or the only deployment if there is just one.
+func (o Objects) deployment(name string) (kubernetes.Deployment, bool) {
+	for _, deployment := range o.Deployments {
+		if deployment.Name == name {
+			return deployment, true
+		}
+	}
+	if len(o.Deployments) == 1 {
+		return o.Deployments[0], true
+	}
+	return kubernetes.Deployment{}, false
+}
+
+func (o Objects) configMap(name string) (kubernetes.ConfigMap, bool) {
+	for _, configMap := range o.ConfigMaps {
+		if configMap.Name == name {
+			return configMap, true
+		}
+	}
+	return kubernetes.ConfigMap{}, false
+}
+
+// container returns the application container of a deployment, which is named after the deployment.
+func container(deployment kubernetes.Deployment) kubernetes.Container {
+	containers := deployment.Spec.Template.Spec.Containers
+	for _, c := range containers {
+		if c.Name == deployment.Name {
+			return c
+		}
+	}
+	if len(containers) > 0 {
+		return containers[0]
+	}
+	return kubernetes.Container{}
+}
+
+func compareDeployment(objects Objects, deployment, migrated kubernetes.Deployment, classifier redact.Classifier, rep *report.Report) {
+	source := fmt.Sprintf("live:deployment/%s", deployment.Name)
+	live := container(deployment)
+	converted := container(migrated)
+
+	if live.Image != converted.Image {
+		rep.Infof(source, "Image differs; live '%s', migrated '%s'.", live.Image, converted.Image)
+	}
+
+	compareEnv(objects, source, live, converted, classifier, rep)
+	compareMounts(source, live, converted, rep)
+	compareResources(source, live.Resources, converted.Resources, rep)
+	compareProbe(source, "liveness", live, live.LivenessProbe, converted.LivenessProbe, rep)
+	compareProbe(source, "readiness", live, live.ReadinessProbe, converted.ReadinessProbe, rep)
+}
+
+// env returns the environment of a container, including variables from config maps.
+// Variables set from references are left out, as their values are not part of the container.
+func env(objects Objects, source string, c kubernetes.Container, rep *report.Report) map[string]string {
+	vars := make(map[string]string)
+
+	for _, from := range c.EnvFrom {
+		switch {
+		case from.ConfigMapRef != nil:
+			configMap, ok := objects.configMap(from.ConfigMapRef.Name)
+			if !ok {
+				rep.Warnf(source, "Environment variables from config map '%s' can not be compared, as it is not among the live objects.", from.ConfigMapRef.Name)
+				continue
+			}
+			for k, v := range configMap.Data {
+				vars[k] = v
+			}
+		case from.SecretRef != nil:
+			rep.Infof(source, "Environment variables from secret '%s' can not be compared.", from.SecretRef.Name)
+		}
+	}
+
+	for _, v := range c.Env {
+		if v.ValueFrom == nil {
+			vars[v.Name] = v.Value
+		}
+	}
+
+	return vars
+}
+
+// compareEnv reports live environment variables that are missing from the migrated application, or have other values.
+// Only the presence of variables set from references is compared, as their values may be read from secrets.
+func compareEnv(objects Objects, source string, live, migrated kubernetes.Container, classifier redact.Classifier, rep *report.Report) {
+	liveEnv := env(objects, source, live, rep)
+	migratedEnv := env(Objects{}, source, migrated, &report.Report{})
+
+	present := make(map[string]bool, len(migrated.Env))
+	for name := range migratedEnv {
+		present[name] = true
+	}
+	for _, v := range migrated.Env {
+		present[v.Name] = true
+	}
+
+	referenced := make(map[string]bool)
+	names := make([]string, 0, len(liveEnv))
+	for name := range liveEnv {
+		names = append(names, name)
+	}
+	for _, v := range live.Env {
+		if v.ValueFrom != nil {
+			referenced[v.Name] = true
+			names = append(names, v.Name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		value := migratedEnv[name]
+		switch {
+		case !present[name]:
+			rep.Warnf(source, "Environment variable '%s' exists in the live deployment, but is missing from the migrated application.", name)
+		case referenced[name] || value == liveEnv[name]:
+		case sensitive(classifier, name, liveEnv[name], value):
+			rep.Infof(source, "Environment variable '%s' differs; the values are not shown, as they are sensitive.", name)
+		default:
+			rep.Infof(source, "Environment variable '%s' differs; live '%s', migrated '%s'.", name, liveEnv[name], value)
+		}
+	}
+}
+
+// sensitive returns true if the classifier considers a variable sensitive with any of the values.
+func sensitive(classifier redact.Classifier, name string, values ...string) bool {
+	for _, value := range values {
+		if len(classifier.Sensitive(naiserator.EnvVar{Name: name, Value: value})) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func compareMounts(source string, live, migrated kubernetes.Container, rep *report.Report) {
+	mounted := make(map[string]bool, len(migrated.VolumeMounts))
+	for _, mount := range migrated.VolumeMounts {
+		mounted[mount.MountPath] = true
+	}
+
+	for _, mount := range live.VolumeMounts {
+		if mount.MountPath == serviceAccountMount || mounted[mount.MountPath] {
+			continue
+		}
+		rep.Warnf(source, "'%s' is mounted in the live deployment, but not in the migrated application.", mount.MountPath)
+	}
+}
+
+func compareResources(source string, live, migrated kubernetes.ResourceRequirements, rep *report.Report) {
+	compare := func(kind string, live, migrated map[string]string) {
+		for _, resource := range []string{"cpu", "memory"} {
+			if live[resource] != migrated[resource] {
+				rep.Infof(source, "The %s %s differs; live '%s', migrated '%s'.", resource, kind, live[resource], migrated[resource])
+			}
+		}
+	}
+	compare("limit", live.Limits, migrated.Limits)
+	compare("request", live.Requests, migrated.Requests)
+}
+
+func compareProbe(source, name string, c kubernetes.Container, live, migrated *kubernetes.Probe, rep *report.Report) {
+	switch {
+	case live == nil && migrated == nil:
+		return
+	case live == nil:
+		rep.Infof(source, "The live deployment has no %s probe, but the migrated application has.", name)
+		return
+	case migrated == nil:
+		rep.Warnf(source, "The live deployment has a %s probe, but the migrated application has not.", name)
+		return
+	}
+
+	var livePath, migratedPath, livePort, migratedPort string
+	if live.HTTPGet != nil {
+		livePath, livePort = live.HTTPGet.Path, portNumber(c, live.HTTPGet.Port)
+	}
+	if migrated.HTTPGet != nil {
+		migratedPath, migratedPort = migrated.HTTPGet.Path, string(migrated.HTTPGet.Port)
+	}
+
+	differences := []struct {
+		field          string
+		live, migrated string
+	}{
+		{"path", livePath, migratedPath},
+		{"port", livePort, migratedPort},
+		{"initialDelaySeconds", strconv.Itoa(live.InitialDelaySeconds), strconv.Itoa(migrated.InitialDelaySeconds)},
+		{"periodSeconds", strconv.Itoa(live.PeriodSeconds), strconv.Itoa(migrated.PeriodSeconds)},
+		{"failureThreshold", strconv.Itoa(live.FailureThreshold), strconv.Itoa(migrated.FailureThreshold)},
+		{"timeoutSeconds", strconv.Itoa(live.TimeoutSeconds), strconv.Itoa(migrated.TimeoutSeconds)},
+	}
+
+	for _, d := range differences {
+		if d.live != d.migrated {
+			rep.Warnf(source, "The %s probe %s differs; live '%s', migrated '%s'.", name, d.field, d.live, d.migrated)
+		}
+	}
+}
+
+// portNumber resolves a named container port into its number.
+func portNumber(c kubernetes.Container, port kubernetes.IntOrString) string {
+	for _, p := range c.Ports {
+		if p.Name == string(port) {
+			return strconv.Itoa(p.ContainerPort)
+		}
+	}
+	return string(port)
+}
+
+func compareService(live, migrated kubernetes.Service, rep *report.Report) {
+	source := fmt.Sprintf("live:service/%s", live.Name)
+	if len(live.Spec.Ports) == 0 || len(migrated.Spec.Ports) == 0 {
+		return
+	}
+	if live.Spec.Ports[0].Port != migrated.Spec.Ports[0].Port {
+		rep.Warnf(source, "Service port differs; live %d, migrated %d.", live.Spec.Ports[0].Port, migrated.Spec.Ports[0].Port)
+	}
+}
+
+func compareIngresses(live []kubernetes.Ingress, migrated kubernetes.Ingress, rep *report.Report) {
+	routes := make(map[string]bool)
+	for _, rule := range migrated.Spec.Rules {
+		for _, path := range rule.HTTP.Paths {
+			routes[rule.Host+path.Path] = true
+		}
+	}
+
+	for _, ingress := range live {
+		source := fmt.Sprintf("live:ingress/%s", ingress.Name)
+		for _, rule := range ingress.Spec.Rules {
+			for _, path := range rule.HTTP.Paths {
+				p := path.Path
+				if len(p) == 0 {
+					p = "/"
+				}
+				if !routes[rule.Host+p] {
+					rep.Warnf(source, "Ingress 'https://%s%s' exists in the cluster, but not in the migrated application.", rule.Host, p)
+				}
+			}
+		}
+	}
+}
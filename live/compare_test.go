@@ -0,0 +1,90 @@
+package live
+
+import (
+	"github.com/nais/migrator/cluster"
+	"github.com/nais/migrator/config"
+	"github.com/nais/migrator/models/naiserator"
+	"github.com/nais/migrator/redact"
+	"github.com/nais/migrator/report"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func migratedApplication() naiserator.Application {
+	probe := func(path string) naiserator.Probe {
+		return naiserator.Probe{Path: path, Port: 8080, InitialDelay: 20, PeriodSeconds: 10, FailureThreshold: 3, Timeout: 1}
+	}
+
+	return naiserator.Application{
+		ObjectMeta: naiserator.ObjectMeta{Name: "myapp", Namespace: "myteam"},
+		Spec: naiserator.ApplicationSpec{
+			Image: "docker.adeo.no:5000/myteam/myapp:1.0.0",
+			Port:  8080,
+			Env: []naiserator.EnvVar{
+				{Name: "FOO", Value: "bar"},
+				{Name: "CHANGED", Value: "migrated"},
+				{Name: "API_TOKEN", Value: "migrated-token"},
+				{Name: "DB_PASSWORD", Value: "hunter2"},
+			},
+			Ingresses: []string{"https://myapp.nais.preprod.local"},
+			Liveness:  probe("/isalive"),
+			Readiness: probe("/isready"),
+			Replicas:  naiserator.Replicas{Min: 2, Max: 4, CpuThresholdPercentage: 50},
+			Resources: naiserator.ResourceRequirements{
+				Limits:   naiserator.ResourceSpec{Cpu: "500m", Memory: "512Mi"},
+				Requests: naiserator.ResourceSpec{Cpu: "200m", Memory: "256Mi"},
+			},
+			SkipCaBundle: true,
+		},
+	}
+}
+
+func classifier(t *testing.T) redact.Classifier {
+	c, err := redact.NewClassifier(config.Redact{})
+	if err != nil {
+		t.Fatalf("create classifier: %s", err)
+	}
+	return c
+}
+
+func TestCompare(t *testing.T) {
+	objects, err := Load([]string{
+		filepath.Join("testdata", "objects.yaml"),
+		filepath.Join("testdata", "list.yaml"),
+	})
+	if err != nil {
+		t.Fatalf("load live objects: %s", err)
+	}
+	target, err := cluster.Lookup("fss", false)
+	if err != nil {
+		t.Fatalf("look up cluster: %s", err)
+	}
+
+	rep := Compare(objects, migratedApplication(), target, classifier(t))
+
+	// The liveness probe port is named in the live deployment, and is resolved before comparing.
+	// Values of sensitive variables are not shown, and variables set from references are only compared by presence.
+	expected := []report.Finding{
+		{Severity: report.Info, Source: "live:deployment/myapp", Message: "Environment variables from secret 'myapp-secret' can not be compared."},
+		{Severity: report.Info, Source: "live:deployment/myapp", Message: "Environment variable 'API_TOKEN' differs; the values are not shown, as they are sensitive."},
+		{Severity: report.Info, Source: "live:deployment/myapp", Message: "Environment variable 'CHANGED' differs; live 'live', migrated 'migrated'."},
+		{Severity: report.Warning, Source: "live:deployment/myapp", Message: "Environment variable 'FROM_CONFIG_MAP' exists in the live deployment, but is missing from the migrated application."},
+		{Severity: report.Warning, Source: "live:deployment/myapp", Message: "Environment variable 'ONLY_LIVE' exists in the live deployment, but is missing from the migrated application."},
+		{Severity: report.Warning, Source: "live:deployment/myapp", Message: "Environment variable 'POD_IP' exists in the live deployment, but is missing from the migrated application."},
+		{Severity: report.Warning, Source: "live:deployment/myapp", Message: "'/var/run/secrets/naisd.io/certs' is mounted in the live deployment, but not in the migrated application."},
+		{Severity: report.Warning, Source: "live:deployment/myapp", Message: "The readiness probe periodSeconds differs; live '5', migrated '10'."},
+		{Severity: report.Warning, Source: "live:ingress/myapp", Message: "Ingress 'https://myapp-q1.adeo.no/myapp' exists in the cluster, but not in the migrated application."},
+	}
+	if !reflect.DeepEqual(rep.Findings, expected) {
+		t.Fatalf("findings differ:\n got: %v\nwant: %v", rep.Findings, expected)
+	}
+}
+
+func TestCompareWithoutDeployment(t *testing.T) {
+	rep := Compare(Objects{}, migratedApplication(), cluster.Cluster{Name: "dev-fss"}, classifier(t))
+
+	if len(rep.Findings) != 1 || rep.Findings[0].Severity != report.Warning {
+		t.Fatalf("expected a warning that there is no deployment to compare with, got %v", rep.Findings)
+	}
+}
@@ -0,0 +1,126 @@
+// Package live reads Kubernetes objects exported from a cluster using `kubectl get -o yaml`,
+// and compares them with the objects a migrated application would produce.
+package live
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/nais/migrator/models/kubernetes"
+	"gopkg.in/yaml.v2"
+	"io"
+	"io/ioutil"
+)
+
+// Objects are the exported objects belonging to an application.
+type Objects struct {
+	Deployments []kubernetes.Deployment
+	Services    []kubernetes.Service
+	Ingresses   []kubernetes.Ingress
+	ConfigMaps  []kubernetes.ConfigMap
+	Secrets     []kubernetes.Secret
+	Autoscalers []kubernetes.HorizontalPodAutoscaler
+}
+
+// list is the envelope kubectl uses when exporting several objects at once.
+type list struct {
+	Kind  string          `yaml:"kind"`
+	Items []yaml.MapSlice `yaml:"items"`
+}
+
+// Load reads exported objects from YAML files. Files may contain several documents and List objects.
+// Objects of other kinds than those in Objects are ignored.
+func Load(paths []string) (Objects, error) {
+	var objects Objects
+
+	for _, path := range paths {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return objects, fmt.Errorf("read live objects: %s", err)
+		}
+
+		err = objects.decode(data)
+		if err != nil {
+			return objects, fmt.Errorf("decode live objects in %s: %s", path, err)
+		}
+	}
+
+	return objects, nil
+}
+
+func (o *Objects) decode(data []byte) error {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+
+	for {
+		var document yaml.MapSlice
+		err := decoder.Decode(&document)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		err = o.add(document)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// add decodes a single document into an object of its kind, unwrapping lists.
+func (o *Objects) add(document yaml.MapSlice) error {
+	if len(document) == 0 {
+		return nil
+	}
+
+	data, err := yaml.Marshal(document)
+	if err != nil {
+		return err
+	}
+
+	var envelope list
+	err = yaml.Unmarshal(data, &envelope)
+	if err != nil {
+		return err
+	}
+
+	switch envelope.Kind {
+	case "List", "DeploymentList", "ServiceList", "IngressList", "ConfigMapList", "SecretList", "HorizontalPodAutoscalerList":
+		for _, item := range envelope.Items {
+			err = o.add(item)
+			if err != nil {
+				return err
+			}
+		}
+	case "Deployment":
+		var deployment kubernetes.Deployment
+		err = yaml.Unmarshal(data, &deployment)
+		o.Deployments = append(o.Deployments, deployment)
+	case "Service":
+		var service kubernetes.Service
+		err = yaml.Unmarshal(data, &service)
+		o.Services = append(o.Services, service)
+	case "Ingress":
+		var ingress kubernetes.Ingress
+		err = yaml.Unmarshal(data, &ingress)
+		o.Ingresses = append(o.Ingresses, ingress)
+	case "ConfigMap":
+		var configMap kubernetes.ConfigMap
+		err = yaml.Unmarshal(data, &configMap)
+		o.ConfigMaps = append(o.ConfigMaps, configMap)
+	case "Secret":
+		var secret kubernetes.Secret
+		err = yaml.Unmarshal(data, &secret)
+		o.Secrets = append(o.Secrets, secret)
+	case "HorizontalPodAutoscaler":
+		var autoscaler kubernetes.HorizontalPodAutoscaler
+		err = yaml.Unmarshal(data, &autoscaler)
+		o.Autoscalers = append(o.Autoscalers, autoscaler)
+	}
+
+	if err != nil {
+		return fmt.Errorf("%s: %s", envelope.Kind, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,73 @@
+package live
+
+import (
+	"github.com/nais/migrator/models/kubernetes"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	objects, err := Load([]string{
+		filepath.Join("testdata", "objects.yaml"),
+		filepath.Join("testdata", "list.yaml"),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// The Secret and the empty document are ignored.
+	if len(objects.Deployments) != 1 || len(objects.Services) != 1 || len(objects.Ingresses) != 1 {
+		t.Fatalf("expected a deployment, a service and an ingress from the multi-document file, got %+v", objects)
+	}
+	if len(objects.ConfigMaps) != 1 || len(objects.Autoscalers) != 1 {
+		t.Fatalf("expected a config map and an autoscaler from the List, got %+v", objects)
+	}
+	if objects.ConfigMaps[0].Data["FROM_CONFIG_MAP"] != "value" {
+		t.Errorf("config map data was not read, got %v", objects.ConfigMaps[0].Data)
+	}
+	if objects.Autoscalers[0].Spec.MaxReplicas != 4 {
+		t.Errorf("autoscaler spec was not read, got %+v", objects.Autoscalers[0].Spec)
+	}
+
+	// Ports may be given as numbers or names.
+	ports := []struct {
+		field    string
+		port     kubernetes.IntOrString
+		expected kubernetes.IntOrString
+	}{
+		{"liveness probe port", objects.Deployments[0].Spec.Template.Spec.Containers[0].LivenessProbe.HTTPGet.Port, "http"},
+		{"readiness probe port", objects.Deployments[0].Spec.Template.Spec.Containers[0].ReadinessProbe.HTTPGet.Port, "8080"},
+		{"maxUnavailable", objects.Deployments[0].Spec.Strategy.RollingUpdate.MaxUnavailable, "0"},
+		{"maxSurge", objects.Deployments[0].Spec.Strategy.RollingUpdate.MaxSurge, "25%"},
+		{"service target port", objects.Services[0].Spec.Ports[0].TargetPort, "http"},
+		{"ingress service port", objects.Ingresses[0].Spec.Rules[0].HTTP.Paths[0].Backend.ServicePort, "80"},
+		{"named ingress service port", objects.Ingresses[0].Spec.Rules[1].HTTP.Paths[0].Backend.ServicePort, "http"},
+	}
+	for _, p := range ports {
+		if p.port != p.expected {
+			t.Errorf("%s is '%s', want '%s'", p.field, p.port, p.expected)
+		}
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := map[string]string{
+		"kind: Service\nspec:\n  ports:\n  - port: 80\n    targetPort: [80]\n": "neither a number nor a name",
+		"kind: List\nitems:\n- kind: Deployment\n  spec: [1]\n":                "Deployment",
+		"kind: Service\n  bad: indentation\n":                                  "yaml",
+	}
+
+	for data, expected := range tests {
+		var objects Objects
+		err := objects.decode([]byte(data))
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("%q: expected an error containing '%s', got %v", data, expected, err)
+		}
+	}
+
+	_, err := Load([]string{filepath.Join("testdata", "missing.yaml")})
+	if err == nil {
+		t.Errorf("expected an error for a missing file")
+	}
+}
@@ -0,0 +1,259 @@
+package live
+
+import (
+	"encoding/base64"
+	"fmt"
+	"github.com/nais/migrator/fasit"
+	"github.com/nais/migrator/mapper"
+	"github.com/nais/migrator/models/kubernetes"
+	"github.com/nais/migrator/models/naisd"
+	"github.com/nais/migrator/report"
+	"sort"
+	"strings"
+)
+
+// injected are environment variables naisd set on every deployment. They are not part of the application's configuration.
+var injected = map[string]bool{
+	"APP_NAME":                true,
+	"APP_VERSION":             true,
+	"APP_ENVIRONMENT":         true,
+	"FASIT_ENVIRONMENT_NAME":  true,
+	"HTTP_PROXY":              true,
+	"HTTPS_PROXY":             true,
+	"NO_PROXY":                true,
+	"http_proxy":              true,
+	"https_proxy":             true,
+	"no_proxy":                true,
+	"NAV_TRUSTSTORE_PATH":     true,
+	"NAV_TRUSTSTORE_PASSWORD": true,
+}
+
+// Input is a naisd manifest reconstructed from live objects, for applications whose nais.yaml is lost.
+type Input struct {
+	Manifest naisd.NaisManifest
+	// Env holds the environment of the live application, to be converted like a Fasit resource with the names kept.
+	Env fasit.NaisResource
+	// Ingresses are the URLs served by the live ingresses.
+	Ingresses []string
+	// Sensitive lists environment variables whose values were read from Kubernetes secrets, and should be redacted.
+	Sensitive []string
+	Report    report.Report
+}
+
+// Reconstruct builds a naisd manifest and environment from the live objects of an application.
+// Anything not found among the objects is left at naisd's defaults. Values read from live secrets are only taken
+// if secrets is set, which the caller must only do when they are redacted from the application.
+func Reconstruct(objects Objects, application string, secrets bool) (Input, error) {
+	var input Input
+	rep := &input.Report
+	source := fmt.Sprintf("live:deployment/%s", application)
+
+	deployment, ok := objects.deployment(application)
+	if !ok {
+		return input, fmt.Errorf("there is no live Deployment named '%s'", application)
+	}
+
+	pod := deployment.Spec.Template
+	c := container(deployment)
+	manifest := naisd.DefaultManifest(application)
+
+	manifest.Team = deployment.Labels["team"]
+	manifest.Image = c.Image
+	for _, port := range c.Ports {
+		if port.Name == "http" || len(c.Ports) == 1 {
+			manifest.Port = port.ContainerPort
+		}
+	}
+
+	manifest.Healthcheck.Liveness = probe(c.LivenessProbe)
+	manifest.Healthcheck.Readiness = probe(c.ReadinessProbe)
+	if c.Lifecycle != nil && c.Lifecycle.PreStop != nil && c.Lifecycle.PreStop.HTTPGet != nil {
+		manifest.PreStopHookPath = c.Lifecycle.PreStop.HTTPGet.Path
+	}
+
+	manifest.Resources = naisd.ResourceRequirements{
+		Limits:   naisd.ResourceList{Cpu: c.Resources.Limits["cpu"], Memory: c.Resources.Limits["memory"]},
+		Requests: naisd.ResourceList{Cpu: c.Resources.Requests["cpu"], Memory: c.Resources.Requests["memory"]},
+	}
+
+	manifest.Replicas.Min = deployment.Spec.Replicas
+	manifest.Replicas.Max = deployment.Spec.Replicas
+	for _, autoscaler := range objects.Autoscalers {
+		if autoscaler.Spec.ScaleTargetRef.Name == deployment.Name {
+			manifest.Replicas = naisd.Replicas{
+				Min:                    autoscaler.Spec.MinReplicas,
+				Max:                    autoscaler.Spec.MaxReplicas,
+				CpuThresholdPercentage: autoscaler.Spec.TargetCPUUtilizationPercentage,
+			}
+		}
+	}
+
+	if len(deployment.Spec.Strategy.Type) > 0 {
+		manifest.DeploymentStrategy = deployment.Spec.Strategy.Type
+	}
+
+	annotations := pod.Annotations
+	manifest.Prometheus.Enabled = annotations["prometheus.io/scrape"] == "true"
+	if port, ok := annotations["prometheus.io/port"]; ok {
+		manifest.Prometheus.Port = port
+	}
+	if path, ok := annotations["prometheus.io/path"]; ok {
+		manifest.Prometheus.Path = path
+	}
+	manifest.Logformat = annotations["nais.io/logformat"]
+	manifest.Logtransform = annotations["nais.io/logtransform"]
+
+	for _, init := range pod.Spec.InitContainers {
+		if strings.Contains(init.Image, "vault-sidekick") {
+			manifest.Secrets = true
+		}
+	}
+	for _, sidecar := range pod.Spec.Containers {
+		if sidecar.Name == "elector" {
+			manifest.LeaderElection = true
+		}
+	}
+
+	vars := env(objects, source, c, rep)
+	fromSecrets := make(map[string]bool)
+	for _, v := range c.Env {
+		if v.ValueFrom == nil {
+			continue
+		}
+		value, ok := valueFrom(objects, source, v, secrets, rep)
+		if ok {
+			vars[v.Name] = value
+		} else {
+			delete(vars, v.Name)
+		}
+		fromSecrets[v.Name] = v.ValueFrom.SecretKeyRef != nil
+	}
+
+	input.Env = fasit.NaisResource{
+		Name:         application,
+		ResourceType: mapper.LiveEnvironment,
+		Properties:   make(map[string]string),
+	}
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		value := vars[name]
+		switch {
+		case name == "HTTP_PROXY":
+			manifest.Webproxy = true
+		case strings.HasPrefix(name, "NAIS_"):
+		case injected[name]:
+		default:
+			input.Env.Properties[name] = value
+			if fromSecrets[name] {
+				input.Sensitive = append(input.Sensitive, name)
+			}
+		}
+	}
+
+	for _, ingress := range objects.Ingresses {
+		for _, rule := range ingress.Spec.Rules {
+			for _, path := range rule.HTTP.Paths {
+				input.Ingresses = append(input.Ingresses, "https://"+rule.Host+strings.TrimSuffix(path.Path, "/"))
+			}
+		}
+	}
+	manifest.Ingress.Disabled = len(input.Ingresses) > 0
+
+	rep.Infof(source, "The manifest has been reconstructed from the live Deployment; check it against what the team remembers of nais.yaml.")
+
+	input.Manifest = manifest
+	return input, nil
+}
+
+// valueFrom resolves an environment variable set from a reference, if the referenced object is among the live objects.
+// Values from secrets are candidates for Vault or a Kubernetes secret. They are only taken if secrets is set,
+// so that they are never written to the application in clear text.
+func valueFrom(objects Objects, source string, v kubernetes.EnvVar, secrets bool, rep *report.Report) (string, bool) {
+	from := v.ValueFrom
+
+	switch {
+	case from.SecretKeyRef != nil:
+		ref := from.SecretKeyRef
+		if !secrets {
+			rep.Warnf(source, "Environment variable '%s' is read from key '%s' of secret '%s' and has been left out, to keep the value out of the application; "+
+				"use --redact secret or --redact vault to move it into a Kubernetes secret or Vault.", v.Name, ref.Key, ref.Name)
+			return "", false
+		}
+		value, ok := objects.secretValue(ref.Name, ref.Key)
+		if !ok {
+			rep.Warnf(source, "Environment variable '%s' is read from key '%s' of secret '%s', which is not among the live objects, and has been left out; "+
+				"store the value in Vault, or include the secret in the exported objects.", v.Name, ref.Key, ref.Name)
+			return "", false
+		}
+		rep.Infof(source, "Environment variable '%s' is read from key '%s' of secret '%s'; the value has been taken from the live secret, and is redacted.",
+			v.Name, ref.Key, ref.Name)
+		return value, true
+
+	case from.ConfigMapKeyRef != nil:
+		ref := from.ConfigMapKeyRef
+		configMap, ok := objects.configMap(ref.Name)
+		value, found := configMap.Data[ref.Key]
+		if !ok || !found {
+			rep.Warnf(source, "Environment variable '%s' is read from key '%s' of config map '%s', which is not among the live objects, and has been left out.",
+				v.Name, ref.Key, ref.Name)
+			return "", false
+		}
+		rep.Infof(source, "Environment variable '%s' is read from key '%s' of config map '%s'; the value has been taken from the live config map.",
+			v.Name, ref.Key, ref.Name)
+		return value, true
+
+	case from.FieldRef != nil:
+		rep.Warnf(source, "Environment variable '%s' is set from the field '%s' and has been left out; add it using valueFrom.fieldRef in the application.",
+			v.Name, from.FieldRef.FieldPath)
+
+	case from.ResourceFieldRef != nil:
+		rep.Warnf(source, "Environment variable '%s' is set from the resource '%s', which Naiserator does not support, and has been left out.",
+			v.Name, from.ResourceFieldRef.Resource)
+
+	default:
+		rep.Warnf(source, "Environment variable '%s' is set from an unknown source and has been left out.", v.Name)
+	}
+
+	return "", false
+}
+
+// secretValue returns the decoded value of a key in a live secret.
+func (o Objects) secretValue(name, key string) (string, bool) {
+	for _, secret := range o.Secrets {
+		if secret.Name != name {
+			continue
+		}
+		if value, ok := secret.StringData[key]; ok {
+			return value, true
+		}
+		encoded, ok := secret.Data[key]
+		if !ok {
+			return "", false
+		}
+		value, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return "", false
+		}
+		return string(value), true
+	}
+	return "", false
+}
+
+// probe converts an HTTP probe back into the naisd format, where the path is relative.
+func probe(p *kubernetes.Probe) naisd.Probe {
+	if p == nil || p.HTTPGet == nil {
+		return naisd.Probe{}
+	}
+	return naisd.Probe{
+		Path:             strings.TrimPrefix(p.HTTPGet.Path, "/"),
+		InitialDelay:     p.InitialDelaySeconds,
+		PeriodSeconds:    p.PeriodSeconds,
+		FailureThreshold: p.FailureThreshold,
+		Timeout:          p.TimeoutSeconds,
+	}
+}
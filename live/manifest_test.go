@@ -0,0 +1,107 @@
+package live
+
+import (
+	"github.com/nais/migrator/models/kubernetes"
+	"github.com/nais/migrator/report"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func valueFromObjects() Objects {
+	env := []kubernetes.EnvVar{
+		{Name: "PLAIN", Value: "value"},
+		{Name: "spring.profiles.active", Value: "prod"},
+		{Name: "DB_PASSWORD", ValueFrom: &kubernetes.EnvVarSource{SecretKeyRef: &kubernetes.KeySelector{Name: "db", Key: "password"}}},
+		{Name: "API_TOKEN", ValueFrom: &kubernetes.EnvVarSource{SecretKeyRef: &kubernetes.KeySelector{Name: "api", Key: "token"}}},
+		{Name: "MISSING_SECRET", ValueFrom: &kubernetes.EnvVarSource{SecretKeyRef: &kubernetes.KeySelector{Name: "other", Key: "key"}}},
+		{Name: "LEVEL", ValueFrom: &kubernetes.EnvVarSource{ConfigMapKeyRef: &kubernetes.KeySelector{Name: "settings", Key: "level"}}},
+		{Name: "MISSING_KEY", ValueFrom: &kubernetes.EnvVarSource{ConfigMapKeyRef: &kubernetes.KeySelector{Name: "settings", Key: "missing"}}},
+		{Name: "POD_IP", ValueFrom: &kubernetes.EnvVarSource{FieldRef: &kubernetes.ObjectFieldSelector{FieldPath: "status.podIP"}}},
+		{Name: "CPU_LIMIT", ValueFrom: &kubernetes.EnvVarSource{ResourceFieldRef: &kubernetes.ResourceFieldSelector{Resource: "limits.cpu"}}},
+	}
+
+	return Objects{
+		Deployments: []kubernetes.Deployment{{
+			ObjectMeta: kubernetes.ObjectMeta{Name: "app"},
+			Spec: kubernetes.DeploymentSpec{Template: kubernetes.PodTemplateSpec{Spec: kubernetes.PodSpec{
+				Containers: []kubernetes.Container{{Name: "app", Image: "app:1", Env: env}},
+			}}},
+		}},
+		ConfigMaps: []kubernetes.ConfigMap{
+			{ObjectMeta: kubernetes.ObjectMeta{Name: "settings"}, Data: map[string]string{"level": "debug"}},
+		},
+		Secrets: []kubernetes.Secret{
+			{ObjectMeta: kubernetes.ObjectMeta{Name: "db"}, Data: map[string]string{"password": "aHVudGVyMg=="}},
+			{ObjectMeta: kubernetes.ObjectMeta{Name: "api"}, StringData: map[string]string{"token": "abc"}},
+		},
+	}
+}
+
+func TestReconstructValueFrom(t *testing.T) {
+	input, err := Reconstruct(valueFromObjects(), "app", true)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// Names are kept as they are, as the application reads them by these names.
+	properties := map[string]string{"PLAIN": "value", "spring.profiles.active": "prod", "DB_PASSWORD": "hunter2", "API_TOKEN": "abc", "LEVEL": "debug"}
+	if !reflect.DeepEqual(input.Env.Properties, properties) {
+		t.Errorf("properties differ:\n got: %v\nwant: %v", input.Env.Properties, properties)
+	}
+
+	sensitive := []string{"API_TOKEN", "DB_PASSWORD"}
+	if !reflect.DeepEqual(input.Sensitive, sensitive) {
+		t.Errorf("sensitive variables are %v, want %v", input.Sensitive, sensitive)
+	}
+
+	// Each variable set from a reference is reported with the kind of reference.
+	expected := map[string]string{
+		"DB_PASSWORD":    "key 'password' of secret 'db'; the value has been taken",
+		"API_TOKEN":      "key 'token' of secret 'api'; the value has been taken",
+		"MISSING_SECRET": "secret 'other', which is not among the live objects",
+		"LEVEL":          "key 'level' of config map 'settings'; the value has been taken",
+		"MISSING_KEY":    "config map 'settings', which is not among the live objects",
+		"POD_IP":         "the field 'status.podIP'",
+		"CPU_LIMIT":      "the resource 'limits.cpu'",
+	}
+	for name, message := range expected {
+		if !reported(input.Report, "'"+name+"'", message) {
+			t.Errorf("no finding for '%s' containing \"%s\"; findings: %v", name, message, input.Report.Findings)
+		}
+	}
+}
+
+func reported(rep report.Report, parts ...string) bool {
+	for _, finding := range rep.Findings {
+		found := true
+		for _, part := range parts {
+			found = found && strings.Contains(finding.Message, part)
+		}
+		if found {
+			return true
+		}
+	}
+	return false
+}
+
+func TestReconstructWithoutSecrets(t *testing.T) {
+	input, err := Reconstruct(valueFromObjects(), "app", false)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// Values of live secrets are left out unless they are redacted.
+	properties := map[string]string{"PLAIN": "value", "spring.profiles.active": "prod", "LEVEL": "debug"}
+	if !reflect.DeepEqual(input.Env.Properties, properties) {
+		t.Errorf("properties differ:\n got: %v\nwant: %v", input.Env.Properties, properties)
+	}
+	if len(input.Sensitive) > 0 {
+		t.Errorf("no variables are sensitive when secrets are left out, got %v", input.Sensitive)
+	}
+	for _, name := range []string{"DB_PASSWORD", "API_TOKEN", "MISSING_SECRET"} {
+		if !reported(input.Report, "'"+name+"'", "has been left out, to keep the value out of the application") {
+			t.Errorf("no finding for '%s' being left out; findings: %v", name, input.Report.Findings)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package mapper
+
+import (
+	"github.com/nais/migrator/cluster"
+	"github.com/nais/migrator/fasit"
+	"github.com/nais/migrator/models/naiserator"
+	"github.com/nais/migrator/report"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+var (
+	hostnamePattern = regexp.MustCompile(`^[a-zA-Z0-9-]+(\.[a-zA-Z0-9-]+)+$`)
+	oracleHost      = regexp.MustCompile(`(?i)(?:@//|@|host=)([a-zA-Z0-9.-]+)`)
+)
+
+// hostOf extracts the host name from a property value, if it looks like a URL or a JDBC URL.
+// Plain host names are only recognized in properties named like a host, as many other values contain dots.
+func hostOf(key, value string) string {
+	value = strings.TrimSpace(value)
+
+	if strings.HasPrefix(value, "jdbc:oracle:") {
+		match := oracleHost.FindStringSubmatch(value)
+		if match != nil {
+			return strings.ToLower(match[1])
+		}
+		return ""
+	}
+
+	value = strings.TrimPrefix(value, "jdbc:")
+	if strings.Contains(value, "://") {
+		u, err := url.Parse(value)
+		if err != nil {
+			return ""
+		}
+		return strings.ToLower(u.Hostname())
+	}
+
+	if strings.Contains(strings.ToLower(key), "host") && hostnamePattern.MatchString(value) {
+		return strings.ToLower(value)
+	}
+
+	return ""
+}
+
+type propertyHost struct {
+	key  string
+	host string
+}
+
+// propertyHosts returns the hosts referenced by the properties of a resource, with the first property naming each.
+func propertyHosts(resource fasit.NaisResource) []propertyHost {
+	var hosts []propertyHost
+	seen := make(map[string]bool)
+
+	for _, key := range sortedKeys(resource.Properties) {
+		host := hostOf(key, resource.Properties[key])
+		if len(host) == 0 || seen[host] {
+			continue
+		}
+		seen[host] = true
+		hosts = append(hosts, propertyHost{key: key, host: host})
+	}
+
+	return hosts
+}
+
+// unreachableHosts reports hosts referenced by the properties of a resource that are only reachable
+// from on-premises clusters, when migrating to GCP.
+func unreachableHosts(ctx ResourceContext, resource fasit.NaisResource, rep *report.Report) {
+	if !ctx.Cluster.GCP {
+		return
+	}
+	for _, ph := range propertyHosts(resource) {
+		if cluster.OnPremHost(ph.host) {
+			rep.Errorf(source(resource), "Property '%s' points at on-premises host '%s', which is not reachable from GCP", ph.key, ph.host)
+		}
+	}
+}
+
+// externalHosts returns access policy rules for the hosts referenced by the properties of a resource.
+// Hosts served by the NAIS clusters are not external, and on-premises hosts are left out when migrating to GCP,
+// as unreachableHosts reports them.
+func externalHosts(ctx ResourceContext, resource fasit.NaisResource) []naiserator.AccessPolicyExternalRule {
+	var rules []naiserator.AccessPolicyExternalRule
+
+	for _, ph := range propertyHosts(resource) {
+		if cluster.NaisHost(ph.host) || (ctx.Cluster.GCP && cluster.OnPremHost(ph.host)) {
+			continue
+		}
+		rules = append(rules, naiserator.AccessPolicyExternalRule{Host: ph.host})
+	}
+
+	return rules
+}
+
+// outboundRules deduplicates the external hosts contributed by resource handlers.
+func outboundRules(external []naiserator.AccessPolicyExternalRule, rep *report.Report) []naiserator.AccessPolicyExternalRule {
+	var rules []naiserator.AccessPolicyExternalRule
+	seen := make(map[string]bool)
+
+	for _, rule := range external {
+		if seen[rule.Host] {
+			continue
+		}
+		seen[rule.Host] = true
+		rules = append(rules, rule)
+		rep.Infof("accessPolicy", "Outbound access to '%s' has been added to the access policy", rule.Host)
+	}
+
+	return rules
+}
+
+// istioInbound scaffolds the inbound access policy for applications in the service mesh.
+// naisd did not restrict traffic between applications, so all applications in the namespace are let in
+// until the team has listed the applications that actually call it.
+func istioInbound(namespace string, rep *report.Report) naiserator.AccessPolicyInbound {
+	rep.Warnf("istio", "Istio is enabled, and Naiserator denies inbound traffic not allowed by the access policy. "+
+		"Inbound traffic is allowed from all applications in namespace '%s'; replace this rule with the applications that call yours.", namespace)
+
+	return naiserator.AccessPolicyInbound{
+		Rules: []naiserator.AccessPolicyRule{
+			{
+				Application: "*",
+				Namespace:   namespace,
+			},
+		},
+	}
+}
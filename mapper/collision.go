@@ -0,0 +1,130 @@
+package mapper
+
+import (
+	"fmt"
+	"github.com/nais/migrator/fasit"
+	"github.com/nais/migrator/models/naiserator"
+	"github.com/nais/migrator/report"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// CollisionStrategy decides what happens to environment variables that end up with the same name
+// as a variable from another property or resource.
+type CollisionStrategy string
+
+const (
+	// CollisionKeep keeps the variable that takes precedence, and drops the others.
+	CollisionKeep CollisionStrategy = "keep"
+	// CollisionRename keeps the variable that takes precedence, and renames the others by appending their resource alias.
+	CollisionRename CollisionStrategy = "rename"
+	// CollisionFail stops the conversion.
+	CollisionFail CollisionStrategy = "fail"
+)
+
+var invalidEnvChars = regexp.MustCompile(`[^A-Z0-9_]`)
+
+// origin is an environment variable together with the resource it was converted from.
+type origin struct {
+	env      naiserator.EnvVar
+	resource fasit.NaisResource
+}
+
+// precedes decides which of two colliding variables wins. Application properties, and the environment of a live
+// deployment, are specific to the application, and win over shared resources. Otherwise the resource with the alias
+// that sorts first wins. Within a resource, the property that sorts first wins, as handlers convert properties in sorted order.
+func precedes(a, b origin) bool {
+	aSpecific := specific(a.resource)
+	bSpecific := specific(b.resource)
+	if aSpecific != bSpecific {
+		return aSpecific
+	}
+	return a.resource.Name < b.resource.Name
+}
+
+func specific(resource fasit.NaisResource) bool {
+	return resource.ResourceType == "applicationproperties" || resource.ResourceType == LiveEnvironment
+}
+
+// resolveCollisions finds environment variables with the same name, and resolves them using the strategy.
+// The order of the remaining variables is preserved.
+func resolveCollisions(origins []origin, strategy CollisionStrategy, rep *report.Report) ([]naiserator.EnvVar, error) {
+	switch strategy {
+	case "", CollisionKeep, CollisionRename, CollisionFail:
+	default:
+		return nil, fmt.Errorf("unknown environment variable collision strategy '%s'", strategy)
+	}
+
+	positions := make(map[string][]int)
+	taken := make(map[string]bool)
+	var names []string
+
+	for i, o := range origins {
+		if len(positions[o.env.Name]) == 1 {
+			names = append(names, o.env.Name)
+		}
+		positions[o.env.Name] = append(positions[o.env.Name], i)
+		taken[o.env.Name] = true
+	}
+	sort.Strings(names)
+
+	drop := make(map[int]bool)
+	renamed := make(map[int]string)
+	var collisions []string
+
+	for _, name := range names {
+		colliding := positions[name]
+		sort.SliceStable(colliding, func(a, b int) bool {
+			return precedes(origins[colliding[a]], origins[colliding[b]])
+		})
+		winner := origins[colliding[0]].resource
+
+		for _, i := range colliding[1:] {
+			loser := origins[i].resource
+			collision := fmt.Sprintf("Environment variable '%s' from resource '%s' collides with the one from resource '%s'", name, loser.Name, winner.Name)
+			if loser.Name == winner.Name {
+				collision = fmt.Sprintf("Several properties in resource '%s' become environment variable '%s'", loser.Name, name)
+			}
+
+			switch strategy {
+			case CollisionFail:
+				collisions = append(collisions, collision)
+			case CollisionRename:
+				newName := uniqueName(name+"_"+invalidEnvChars.ReplaceAllString(strings.ToUpper(loser.Name), "_"), taken)
+				taken[newName] = true
+				renamed[i] = newName
+				rep.Warnf(source(loser), "%s; it has been renamed to '%s'.", collision, newName)
+			default:
+				drop[i] = true
+				rep.Warnf(source(loser), "%s; it has been left out.", collision)
+			}
+		}
+	}
+
+	if len(collisions) > 0 {
+		return nil, fmt.Errorf("environment variable names collide: %s", strings.Join(collisions, "; "))
+	}
+
+	env := make([]naiserator.EnvVar, 0, len(origins))
+	for i, o := range origins {
+		if drop[i] {
+			continue
+		}
+		if newName, ok := renamed[i]; ok {
+			o.env.Name = newName
+		}
+		env = append(env, o.env)
+	}
+
+	return env, nil
+}
+
+// uniqueName appends a number to the name if it is already taken.
+func uniqueName(name string, taken map[string]bool) string {
+	candidate := name
+	for i := 2; taken[candidate]; i++ {
+		candidate = fmt.Sprintf("%s_%d", name, i)
+	}
+	return candidate
+}
@@ -0,0 +1,178 @@
+package mapper
+
+import (
+	"github.com/nais/migrator/fasit"
+	"github.com/nais/migrator/models/naisd"
+	"github.com/nais/migrator/models/naiserator"
+	"github.com/nais/migrator/report"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var (
+	appProperties = fasit.NaisResource{Name: "app-config", ResourceType: "applicationproperties"}
+	serviceA      = fasit.NaisResource{Name: "aservice", ResourceType: "restservice"}
+	serviceB      = fasit.NaisResource{Name: "bservice", ResourceType: "restservice"}
+	liveEnv       = fasit.NaisResource{Name: "app", ResourceType: LiveEnvironment}
+)
+
+func env(name, value string) naiserator.EnvVar {
+	return naiserator.EnvVar{Name: name, Value: value}
+}
+
+func TestPrecedes(t *testing.T) {
+	tests := []struct {
+		name     string
+		a, b     fasit.NaisResource
+		precedes bool
+	}{
+		{name: "application properties win over resources", a: appProperties, b: serviceA, precedes: true},
+		{name: "resources lose to application properties", a: serviceA, b: appProperties, precedes: false},
+		{name: "the live environment wins over resources", a: liveEnv, b: serviceA, precedes: true},
+		{name: "the alias that sorts first wins", a: serviceA, b: serviceB, precedes: true},
+		{name: "the alias that sorts last loses", a: serviceB, b: serviceA, precedes: false},
+		{name: "the same resource does not precede itself", a: serviceA, b: serviceA, precedes: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a := origin{env: env("URL", "a"), resource: test.a}
+			b := origin{env: env("URL", "b"), resource: test.b}
+			if precedes(a, b) != test.precedes {
+				t.Errorf("precedes is %t, want %t", !test.precedes, test.precedes)
+			}
+		})
+	}
+}
+
+// collidingOrigins lists the loser before the winner, so that resolving depends on precedence rather than order.
+func collidingOrigins() []origin {
+	return []origin{
+		{env: env("LOG_LEVEL", "info"), resource: appProperties},
+		{env: env("URL", "b"), resource: serviceB},
+		{env: env("TIMEOUT", "10"), resource: serviceB},
+		{env: env("URL", "a"), resource: serviceA},
+		{env: env("URL", "app"), resource: appProperties},
+	}
+}
+
+func TestResolveCollisions(t *testing.T) {
+	tests := []struct {
+		strategy CollisionStrategy
+		env      []naiserator.EnvVar
+		warnings []string
+	}{
+		{
+			strategy: "",
+			env:      []naiserator.EnvVar{env("LOG_LEVEL", "info"), env("TIMEOUT", "10"), env("URL", "app")},
+			warnings: []string{"from resource 'bservice' collides with the one from resource 'app-config'; it has been left out", "from resource 'aservice' collides with the one from resource 'app-config'; it has been left out"},
+		},
+		{
+			strategy: CollisionKeep,
+			env:      []naiserator.EnvVar{env("LOG_LEVEL", "info"), env("TIMEOUT", "10"), env("URL", "app")},
+			warnings: []string{"from resource 'bservice' collides", "from resource 'aservice' collides"},
+		},
+		{
+			strategy: CollisionRename,
+			env: []naiserator.EnvVar{
+				env("LOG_LEVEL", "info"), env("URL_BSERVICE", "b"), env("TIMEOUT", "10"), env("URL_ASERVICE", "a"), env("URL", "app"),
+			},
+			warnings: []string{"renamed to 'URL_ASERVICE'", "renamed to 'URL_BSERVICE'"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(string(test.strategy), func(t *testing.T) {
+			var rep report.Report
+			resolved, err := resolveCollisions(collidingOrigins(), test.strategy, &rep)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !reflect.DeepEqual(resolved, test.env) {
+				t.Errorf("resolved variables differ:\n got: %v\nwant: %v", resolved, test.env)
+			}
+			if len(rep.Findings) != len(test.warnings) {
+				t.Fatalf("expected %d warnings, got %v", len(test.warnings), rep.Findings)
+			}
+			for _, warning := range test.warnings {
+				if !reported(rep, report.Warning, warning) {
+					t.Errorf("no warning containing '%s' in %v", warning, rep.Findings)
+				}
+			}
+		})
+	}
+}
+
+func TestResolveCollisionsFail(t *testing.T) {
+	var rep report.Report
+	_, err := resolveCollisions(collidingOrigins(), CollisionFail, &rep)
+	if err == nil {
+		t.Fatal("expected colliding names to fail the conversion")
+	}
+	for _, alias := range []string{"aservice", "bservice"} {
+		if !strings.Contains(err.Error(), "from resource '"+alias+"' collides") {
+			t.Errorf("the error does not mention resource '%s': %s", alias, err)
+		}
+	}
+
+	_, err = resolveCollisions(collidingOrigins(), "merge", &rep)
+	if err == nil || !strings.Contains(err.Error(), "unknown environment variable collision strategy 'merge'") {
+		t.Errorf("expected an error for an unknown strategy, got %v", err)
+	}
+}
+
+func TestResolveCollisionsWithinResource(t *testing.T) {
+	origins := []origin{
+		{env: env("FOO_BAR", "dotted"), resource: serviceA},
+		{env: env("FOO_BAR", "underscored"), resource: serviceA},
+	}
+
+	var rep report.Report
+	resolved, err := resolveCollisions(origins, CollisionRename, &rep)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := []naiserator.EnvVar{env("FOO_BAR", "dotted"), env("FOO_BAR_ASERVICE", "underscored")}
+	if !reflect.DeepEqual(resolved, expected) {
+		t.Errorf("resolved variables differ:\n got: %v\nwant: %v", resolved, expected)
+	}
+	if !reported(rep, report.Warning, "Several properties in resource 'aservice' become environment variable 'FOO_BAR'") {
+		t.Errorf("expected a warning about properties within the resource, got %v", rep.Findings)
+	}
+}
+
+func TestRenameBeforeCollisions(t *testing.T) {
+	ctx := ResourceContext{Deploy: naisd.Deploy{Application: "app", FasitEnvironment: "q1"}}
+	resources := []fasit.NaisResource{
+		{Name: "aservice", ResourceType: "restservice", Properties: map[string]string{"url": "https://a.example.com"}},
+		{Name: "bservice", ResourceType: "restservice", Properties: map[string]string{"url": "https://b.example.com"}},
+	}
+	renames := map[string]string{"BSERVICE_URL": "ASERVICE_URL"}
+
+	converted, err := convertResources(ctx, resources, CollisionKeep, renames)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := []naiserator.EnvVar{env("ASERVICE_URL", "https://a.example.com")}
+	if !reflect.DeepEqual(converted.Env, expected) {
+		t.Errorf("a variable renamed onto another name is a collision:\n got: %v\nwant: %v", converted.Env, expected)
+	}
+	if !reported(converted.Report, report.Warning, "'ASERVICE_URL' from resource 'bservice' collides") {
+		t.Errorf("expected the collision caused by the rename to be reported, got %v", converted.Report.Findings)
+	}
+
+	_, err = convertResources(ctx, resources, CollisionFail, renames)
+	if err == nil {
+		t.Error("expected the collision caused by the rename to fail the conversion")
+	}
+}
+
+func reported(rep report.Report, severity report.Severity, message string) bool {
+	for _, finding := range rep.Findings {
+		if finding.Severity == severity && strings.Contains(finding.Message, message) {
+			return true
+		}
+	}
+	return false
+}
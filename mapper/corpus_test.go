@@ -0,0 +1,175 @@
+package mapper
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/nais/migrator/config"
+	"github.com/nais/migrator/fasit"
+	"github.com/nais/migrator/input"
+	"github.com/nais/migrator/models/naisd"
+	"github.com/nais/migrator/report"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// corpusDeploy holds the deployment parameters of a corpus case, as given on the command line or in migrator.yaml.
+type corpusDeploy struct {
+	Application      string           `yaml:"application"`
+	Zone             string           `yaml:"zone"`
+	FasitEnvironment string           `yaml:"fasitEnvironment"`
+	Namespace        string           `yaml:"namespace"`
+	Target           Target           `yaml:"target"`
+	Overrides        config.Overrides `yaml:"overrides"`
+}
+
+// corpusResult is what a corpus case is compared with, besides the converted application.
+type corpusResult struct {
+	report.Report `yaml:",inline"`
+	Error         string `yaml:"error,omitempty"`
+}
+
+// corpusCase is a naisd manifest with its deployment parameters and Fasit resources.
+type corpusCase struct {
+	manifest  naisd.NaisManifest
+	decoded   report.Report
+	deploy    naisd.Deploy
+	options   Options
+	resources []fasit.NaisResource
+}
+
+// loadCase reads a case directory holding nais.yaml, deploy.yaml and optionally fasit.json,
+// which is a snapshot of the resources returned by fasit.FetchFasitResources.
+func loadCase(t *testing.T, dir string) corpusCase {
+	var c corpusCase
+	var params corpusDeploy
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "deploy.yaml"))
+	if err != nil {
+		t.Fatalf("read deployment parameters: %s", err)
+	}
+	err = yaml.UnmarshalStrict(data, &params)
+	if err != nil {
+		t.Fatalf("decode deployment parameters: %s", err)
+	}
+	c.deploy = naisd.Deploy{
+		Application:      params.Application,
+		Zone:             params.Zone,
+		FasitEnvironment: params.FasitEnvironment,
+		Namespace:        params.Namespace,
+	}
+	c.options = Options{Overrides: params.Overrides, Target: params.Target}
+
+	data, err = ioutil.ReadFile(filepath.Join(dir, "nais.yaml"))
+	if err != nil {
+		t.Fatalf("read manifest: %s", err)
+	}
+	c.manifest, c.decoded, err = input.Decode(data, c.deploy.Application, true)
+	if err != nil {
+		t.Fatalf("decode manifest: %s", err)
+	}
+
+	data, err = ioutil.ReadFile(filepath.Join(dir, "fasit.json"))
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatalf("read fasit resources: %s", err)
+	}
+	if err == nil {
+		err = json.Unmarshal(data, &c.resources)
+		if err != nil {
+			t.Fatalf("decode fasit resources: %s", err)
+		}
+	}
+
+	return c
+}
+
+// convert runs the case through Convert, and returns the converted application and the findings.
+// The application is empty if conversion fails; the error is then part of the findings.
+func (c corpusCase) convert(t *testing.T, resources []fasit.NaisResource) ([]byte, []byte) {
+	var application []byte
+
+	app, rep, err := Convert(c.manifest, c.deploy, resources, c.options)
+	result := corpusResult{Report: c.decoded}
+	result.Merge(rep)
+	if err != nil {
+		result.Error = err.Error()
+	} else {
+		application, err = yaml.Marshal(app)
+		if err != nil {
+			t.Fatalf("encode application: %s", err)
+		}
+	}
+
+	findings, err := yaml.Marshal(result)
+	if err != nil {
+		t.Fatalf("encode findings: %s", err)
+	}
+
+	return application, findings
+}
+
+// TestCorpus converts every case in testdata/corpus, and compares the result with naiserator.yaml and findings.yaml
+// in the case directory. Run with -update to regenerate them, and review the changes as a diff.
+func TestCorpus(t *testing.T) {
+	dirs, err := filepath.Glob(filepath.Join("testdata", "corpus", "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dirs) == 0 {
+		t.Fatal("no cases in testdata/corpus")
+	}
+
+	for _, dir := range dirs {
+		dir := dir
+		t.Run(filepath.Base(dir), func(t *testing.T) {
+			c := loadCase(t, dir)
+			application, findings := c.convert(t, c.resources)
+
+			if *update {
+				writeExpected(t, filepath.Join(dir, "naiserator.yaml"), application)
+				writeExpected(t, filepath.Join(dir, "findings.yaml"), findings)
+			}
+			compareExpected(t, filepath.Join(dir, "naiserator.yaml"), application)
+			compareExpected(t, filepath.Join(dir, "findings.yaml"), findings)
+
+			// Maps are iterated in random order, so the output must be the same on every run,
+			// with the resources in any order.
+			random := rand.New(rand.NewSource(shuffleSeed))
+			for i := 0; i < 10; i++ {
+				again, againFindings := c.convert(t, shuffled(c.resources, random))
+				if !bytes.Equal(again, application) || !bytes.Equal(againFindings, findings) {
+					t.Fatalf("run %d differs from the first run\n%s\n%s", i, again, againFindings)
+				}
+			}
+		})
+	}
+}
+
+// writeExpected writes expected output, removing the file if there is none.
+func writeExpected(t *testing.T, path string, data []byte) {
+	if len(data) == 0 {
+		err := os.Remove(path)
+		if err != nil && !os.IsNotExist(err) {
+			t.Fatalf("remove %s: %s", path, err)
+		}
+		return
+	}
+	err := ioutil.WriteFile(path, data, 0644)
+	if err != nil {
+		t.Fatalf("update %s: %s", path, err)
+	}
+}
+
+// compareExpected compares output with the expected file. A missing file is expected to be empty output.
+func compareExpected(t *testing.T, path string, actual []byte) {
+	expected, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatalf("read %s: %s", path, err)
+	}
+	if !bytes.Equal(expected, actual) {
+		t.Errorf("output differs from %s; run with -update if the change is intended\n%s", path, actual)
+	}
+}
@@ -0,0 +1,36 @@
+package mapper
+
+import (
+	"github.com/nais/migrator/fasit"
+)
+
+const (
+	credentialUsername = "username"
+	credentialPassword = "password"
+)
+
+// credentialHandler converts Credential resources. The username stays an environment variable,
+// while the password is mounted from Vault, following the layout of vaultSecrets. naisd provided the password
+// in the environment variable <ALIAS>_PASSWORD, which Naiserator can not do, so the team is told how to
+// read it from the file instead.
+func credentialHandler(ctx ResourceContext, resource fasit.NaisResource) ResourceResult {
+	result := PropertiesHandler(ctx, resource)
+
+	file := secretFile(resource, result.Mounts, credentialPassword)
+	if len(file) == 0 {
+		return result
+	}
+
+	variable := resource.ToEnvironmentVariable(credentialPassword)
+	if username, ok := resource.Properties[credentialUsername]; ok {
+		result.Report.Warnf(source(resource), "Credential '%s' for user '%s' (environment variable '%s'): the password is no longer in '%s'; "+
+			"read it from the file '%s', or set it when the application starts using 'export %s=$(cat %s)'",
+			resource.Name, username, resource.ToEnvironmentVariable(credentialUsername), variable, file, variable, file)
+	} else {
+		result.Report.Warnf(source(resource), "Credential '%s': the password is no longer in '%s'; "+
+			"read it from the file '%s', or set it when the application starts using 'export %s=$(cat %s)'",
+			resource.Name, variable, file, variable, file)
+	}
+
+	return result
+}
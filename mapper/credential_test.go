@@ -0,0 +1,124 @@
+package mapper
+
+import (
+	"github.com/nais/migrator/cluster"
+	"github.com/nais/migrator/fasit"
+	"github.com/nais/migrator/models/naisd"
+	"github.com/nais/migrator/models/naiserator"
+	"github.com/nais/migrator/report"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCredentialHandler(t *testing.T) {
+	ctx := ResourceContext{
+		Deploy:  naisd.Deploy{Application: "app", Zone: naisd.ZONE_FSS, FasitEnvironment: "q1"},
+		Cluster: cluster.Cluster{Name: "dev-fss"},
+	}
+
+	tests := []struct {
+		name     string
+		resource fasit.NaisResource
+		env      []naiserator.EnvVar
+		mounts   []naiserator.SecretPath
+		file     string
+	}{
+		{
+			name: "single secret",
+			resource: fasit.NaisResource{
+				Name:         "srvapp",
+				ResourceType: "Credential",
+				Properties:   map[string]string{"username": "srvapp"},
+				Secret:       map[string]string{"password": "/kv/preprod/fss/app/default/srvapp/password"},
+			},
+			env: []naiserator.EnvVar{{Name: "SRVAPP_USERNAME", Value: "srvapp"}},
+			mounts: []naiserator.SecretPath{
+				{KvPath: "/kv/preprod/fss/app/default/srvapp", MountPath: "/var/run/secrets/nais.io/srvapp"},
+			},
+			file: "/var/run/secrets/nais.io/srvapp/password",
+		},
+		{
+			name: "several secrets in the same KV secret share a mount",
+			resource: fasit.NaisResource{
+				Name:         "srvapp",
+				ResourceType: "Credential",
+				Properties:   map[string]string{"username": "srvapp"},
+				Secret: map[string]string{
+					"password": "/kv/preprod/fss/app/default/srvapp/password",
+					"pin":      "/kv/preprod/fss/app/default/srvapp/pin",
+				},
+			},
+			env: []naiserator.EnvVar{{Name: "SRVAPP_USERNAME", Value: "srvapp"}},
+			mounts: []naiserator.SecretPath{
+				{KvPath: "/kv/preprod/fss/app/default/srvapp", MountPath: "/var/run/secrets/nais.io/srvapp"},
+			},
+			file: "/var/run/secrets/nais.io/srvapp/password",
+		},
+		{
+			name: "secrets in different KV secrets get a mount each",
+			resource: fasit.NaisResource{
+				Name:         "srvapp",
+				ResourceType: "Credential",
+				Properties:   map[string]string{"username": "srvapp"},
+				PropertyMap:  map[string]string{"username": "SERVICEUSER_USERNAME"},
+				Secret: map[string]string{
+					"password": "/kv/preprod/fss/app/default/srvapp/password",
+					"apikey":   "/kv/preprod/fss/app/default/gateway/apikey",
+					"unused":   "",
+				},
+			},
+			env: []naiserator.EnvVar{{Name: "SERVICEUSER_USERNAME", Value: "srvapp"}},
+			mounts: []naiserator.SecretPath{
+				{KvPath: "/kv/preprod/fss/app/default/gateway", MountPath: "/var/run/secrets/nais.io/srvapp/gateway"},
+				{KvPath: "/kv/preprod/fss/app/default/srvapp", MountPath: "/var/run/secrets/nais.io/srvapp/srvapp"},
+			},
+			file: "/var/run/secrets/nais.io/srvapp/srvapp/password",
+		},
+		{
+			name: "secrets in KV secrets with the same name get unique mounts",
+			resource: fasit.NaisResource{
+				Name:         "srvapp",
+				ResourceType: "Credential",
+				Secret: map[string]string{
+					"password": "/kv/preprod/fss/app/default/creds/password",
+					"apikey":   "/kv/preprod/fss/other/default/creds/apikey",
+				},
+			},
+			mounts: []naiserator.SecretPath{
+				{KvPath: "/kv/preprod/fss/app/default/creds", MountPath: "/var/run/secrets/nais.io/srvapp/creds"},
+				{KvPath: "/kv/preprod/fss/other/default/creds", MountPath: "/var/run/secrets/nais.io/srvapp/creds_2"},
+			},
+			file: "/var/run/secrets/nais.io/srvapp/creds/password",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Secrets are visited using sortedKeys, so a single run is deterministic.
+			result := credentialHandler(ctx, test.resource)
+
+			if !reflect.DeepEqual(result.Env, test.env) {
+				t.Fatalf("env differs:\n got: %v\nwant: %v", result.Env, test.env)
+			}
+			if !reflect.DeepEqual(result.Mounts, test.mounts) {
+				t.Fatalf("mounts differ:\n got: %v\nwant: %v", result.Mounts, test.mounts)
+			}
+			if file := secretFile(test.resource, result.Mounts, credentialPassword); file != test.file {
+				t.Fatalf("password file is '%s', want '%s'", file, test.file)
+			}
+			if !passwordReported(result.Report, test.file) {
+				t.Fatalf("no warning tells where to read the password; findings: %v", result.Report.Findings)
+			}
+		})
+	}
+}
+
+func passwordReported(rep report.Report, file string) bool {
+	for _, finding := range rep.Findings {
+		if finding.Severity == report.Warning && strings.Contains(finding.Message, "'"+file+"'") && strings.Contains(finding.Message, "_PASSWORD") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,86 @@
+package mapper
+
+import (
+	"fmt"
+	"github.com/nais/migrator/fasit"
+	"github.com/nais/migrator/models/naiserator"
+	"net/url"
+	"strings"
+)
+
+const (
+	dataSourceURL      = "url"
+	dataSourceUsername = "username"
+)
+
+// jdbcURL normalizes a database URL into a JDBC URL, and returns the database engine and name.
+// The database name is only known for URLs with a path, such as PostgreSQL URLs.
+func jdbcURL(value string) (jdbc, engine, database string) {
+	jdbc = strings.TrimSpace(value)
+
+	for _, scheme := range []string{"postgres://", "postgresql://"} {
+		if strings.HasPrefix(jdbc, scheme) {
+			jdbc = "jdbc:postgresql://" + strings.TrimPrefix(jdbc, scheme)
+		}
+	}
+
+	if !strings.HasPrefix(jdbc, "jdbc:") {
+		return jdbc, "", ""
+	}
+
+	engine = strings.SplitN(strings.TrimPrefix(jdbc, "jdbc:"), ":", 2)[0]
+
+	if u, err := url.Parse(strings.TrimPrefix(jdbc, "jdbc:")); err == nil && len(u.Host) > 0 {
+		database = strings.Trim(u.Path, "/")
+	}
+
+	return jdbc, engine, database
+}
+
+// dataSourceHandler converts Fasit DataSource resources into a JDBC URL environment variable and database credentials.
+//
+// PostgreSQL credentials are issued dynamically by Vault where the cluster supports it, and replace the static Fasit
+// credentials. Other databases keep their static credentials from Fasit. Either way the DBA must be involved,
+// so every data source is reported.
+func dataSourceHandler(ctx ResourceContext, resource fasit.NaisResource) ResourceResult {
+	var result ResourceResult
+	target := ctx.Cluster
+	rep := &result.Report
+
+	jdbc, engine, database := jdbcURL(resource.Properties[dataSourceURL])
+	dynamic := engine == "postgresql" && len(database) > 0 && len(target.VaultDatabaseMount) > 0
+
+	for _, key := range sortedKeys(resource.Properties) {
+		value := resource.Properties[key]
+		switch {
+		case key == dataSourceURL:
+			value = jdbc
+		case key == dataSourceUsername && dynamic:
+			continue
+		}
+		result.Env = append(result.Env, naiserator.EnvVar{
+			Name:  resource.ToEnvironmentVariable(key),
+			Value: value,
+		})
+	}
+
+	mountPath := fmt.Sprintf("/var/run/secrets/nais.io/%s", resource.Name)
+
+	switch {
+	case target.GCP:
+		rep.Errorf(source(resource), "Database '%s' (%s) can not be reached from GCP; ask your DBA about migrating it to Cloud SQL", resource.Name, engine)
+	case dynamic:
+		role := fmt.Sprintf("%s-user", database)
+		result.Mounts = append(result.Mounts, naiserator.SecretPath{
+			KvPath:    fmt.Sprintf("%s/creds/%s", target.VaultDatabaseMount, role),
+			MountPath: mountPath,
+		})
+		rep.Warnf(source(resource), "Credentials for database '%s' are issued by Vault and mounted under '%s'; ask your DBA to create the Vault role '%s'",
+			database, mountPath, role)
+	default:
+		result.Mounts = vaultSecrets(resource, rep)
+		rep.Warnf(source(resource), "Database '%s' (%s) keeps static credentials; ask your DBA whether Vault can issue them dynamically", resource.Name, engine)
+	}
+
+	return result
+}
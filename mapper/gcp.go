@@ -0,0 +1,89 @@
+package mapper
+
+import (
+	"fmt"
+	"github.com/nais/migrator/cluster"
+	"github.com/nais/migrator/models/naisd"
+	"github.com/nais/migrator/models/naiserator"
+	"github.com/nais/migrator/report"
+	"strings"
+)
+
+// Target selects where the migrated application will run.
+type Target string
+
+const (
+	// TargetOnPrem migrates to the on-premises cluster serving the naisd zone.
+	TargetOnPrem Target = "onprem"
+	// TargetGCP migrates to the Google Cloud Platform cluster of the same environment class.
+	TargetGCP Target = "gcp"
+)
+
+// ResolveCluster finds the cluster that an application deployed to a naisd zone is migrated to.
+func ResolveCluster(deploy naisd.Deploy, target Target) (cluster.Cluster, error) {
+	switch target {
+	case "", TargetOnPrem:
+		return cluster.ForDeploy(deploy)
+	case TargetGCP:
+		return cluster.Lookup(cluster.ZONE_GCP, cluster.Production(deploy.FasitEnvironment))
+	}
+	return cluster.Cluster{}, fmt.Errorf("unknown target '%s'; valid targets are %s and %s", target, TargetOnPrem, TargetGCP)
+}
+
+// gcpSecrets moves Vault secrets stored under an on-premises cluster's KV prefix to the same path under the
+// prefix of the GCP cluster. The secrets themselves are not moved, and must be copied before the application is
+// deployed.
+func gcpSecrets(paths []naiserator.SecretPath, target cluster.Cluster, rep *report.Report) []naiserator.SecretPath {
+	translated := make([]naiserator.SecretPath, 0, len(paths))
+
+	for _, secret := range paths {
+		prefix := onPremKvPrefix(secret.KvPath)
+		switch {
+		case underPath(secret.KvPath, target.VaultKvPrefix):
+		case len(prefix) > 0:
+			kvPath := target.VaultKvPrefix + strings.TrimPrefix(secret.KvPath, prefix)
+			rep.Warnf("vault", "Secret '%s' is now read from '%s'; copy it there before deploying to cluster '%s'",
+				secret.KvPath, kvPath, target.Name)
+			secret.KvPath = kvPath
+		default:
+			rep.Warnf("vault", "Secret '%s' is stored outside '%s' and must be copied before it can be mounted in cluster '%s'",
+				secret.KvPath, target.VaultKvPrefix, target.Name)
+		}
+		translated = append(translated, secret)
+	}
+
+	return translated
+}
+
+// onPremKvPrefix returns the KV prefix of the on-premises cluster a Vault path belongs to, if any.
+func onPremKvPrefix(kvPath string) string {
+	for _, c := range cluster.All() {
+		if !c.GCP && underPath(kvPath, c.VaultKvPrefix) {
+			return c.VaultKvPrefix
+		}
+	}
+	return ""
+}
+
+func underPath(p, prefix string) bool {
+	return p == prefix || strings.HasPrefix(p, prefix+"/")
+}
+
+// gcpBuckets lists the Cloud Storage buckets the application uses; they are only available in GCP.
+func gcpBuckets(names []string, target cluster.Cluster, rep *report.Report) naiserator.GCP {
+	gcp := naiserator.GCP{}
+	if len(names) == 0 {
+		return gcp
+	}
+
+	if !target.GCP {
+		rep.Warnf("gcp", "Buckets %s are only available in GCP and have been left out of the application in cluster '%s'",
+			strings.Join(names, ", "), target.Name)
+		return gcp
+	}
+
+	for _, name := range names {
+		gcp.Buckets = append(gcp.Buckets, naiserator.CloudStorageBucket{Name: name})
+	}
+	return gcp
+}
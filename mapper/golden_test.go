@@ -0,0 +1,134 @@
+package mapper
+
+import (
+	"bytes"
+	"flag"
+	"github.com/nais/migrator/cluster"
+	"github.com/nais/migrator/config"
+	"github.com/nais/migrator/fasit"
+	"github.com/nais/migrator/models/naisd"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"math/rand"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update golden files in testdata")
+
+func goldenResources() []fasit.NaisResource {
+	return []fasit.NaisResource{
+		{
+			Name:         "app",
+			ResourceType: "applicationproperties",
+			Properties: map[string]string{
+				"zeta":          "last",
+				"alpha":         "first",
+				"foo.bar":       "dotted",
+				"foo_bar":       "underscored",
+				"service.url":   "https://service.nais.adeo.no/api",
+				"mixed.Case.ok": "yes",
+			},
+		},
+		{
+			Name:         "srvapp",
+			ResourceType: "credential",
+			Properties:   map[string]string{"username": "srvapp"},
+			Secret: map[string]string{
+				"password": "/kv/prod/fss/app/default/srvapp/password",
+				"pin":      "/kv/prod/fss/app/default/srvapp/pin",
+				"other":    "/kv/prod/fss/app/default/other/secret",
+			},
+		},
+		{
+			Name:         "mydb",
+			ResourceType: "datasource",
+			Properties: map[string]string{
+				"url":      "jdbc:oracle:thin:@//db.adeo.no:1521/mydb",
+				"username": "mydb",
+			},
+			Secret: map[string]string{"password": "/kv/prod/fss/app/default/mydb/password"},
+		},
+		{
+			Name:         "myservice",
+			ResourceType: "restservice",
+			Properties:   map[string]string{"url": "https://myservice.nais.adeo.no", "description": "the service"},
+		},
+		{
+			ResourceType: "LoadBalancerConfig",
+			Ingresses: []fasit.FasitIngress{
+				{Host: "app.adeo.no", Path: "/app"},
+				{Host: "app.adeo.no", Path: "/app"},
+				{Host: "app.nais.adeo.no"},
+			},
+		},
+	}
+}
+
+func TestConvertGolden(t *testing.T) {
+	target, _ := cluster.Lookup(naisd.ZONE_FSS, true)
+	manifest := naisd.DefaultManifest("app")
+	manifest.Team = "myteam"
+	manifest.Prometheus.Enabled = true
+	deploy := naisd.Deploy{Application: "app", Zone: naisd.ZONE_FSS, FasitEnvironment: "p"}
+	options := Options{
+		Cluster: target,
+		Overrides: config.Overrides{
+			Ingresses: []string{"https://app.intern.nav.no", "https://app.adeo.no/app", "App.Intern.nav.no/"},
+		},
+	}
+
+	convert := func(resources []fasit.NaisResource) []byte {
+		app, rep, err := Convert(manifest, deploy, resources, options)
+		if err != nil {
+			t.Fatalf("convert: %s", err)
+		}
+		var buf bytes.Buffer
+		for _, object := range []interface{}{app, rep} {
+			data, err := yaml.Marshal(object)
+			if err != nil {
+				t.Fatalf("marshal: %s", err)
+			}
+			buf.WriteString("---\n")
+			buf.Write(data)
+		}
+		return buf.Bytes()
+	}
+
+	path := filepath.Join("testdata", "golden", "convert.yaml")
+	expected := convert(goldenResources())
+
+	if *update {
+		err := ioutil.WriteFile(path, expected, 0644)
+		if err != nil {
+			t.Fatalf("update golden file: %s", err)
+		}
+	}
+
+	golden, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read golden file: %s", err)
+	}
+
+	// Maps are iterated in random order, so every run must be checked, with resources in any order.
+	random := rand.New(rand.NewSource(shuffleSeed))
+	for i := 0; i < 20; i++ {
+		resources := shuffled(goldenResources(), random)
+		if actual := convert(resources); !bytes.Equal(actual, golden) {
+			t.Fatalf("run %d differs from %s; run with -update if the change is intended\n%s", i, path, actual)
+		}
+	}
+}
+
+// shuffleSeed makes the order resources are converted in random, but the same on every test run,
+// so that a failure caused by the order can be reproduced.
+const shuffleSeed = 7531
+
+// shuffled returns a copy of the resources in random order.
+func shuffled(resources []fasit.NaisResource, random *rand.Rand) []fasit.NaisResource {
+	s := append([]fasit.NaisResource{}, resources...)
+	random.Shuffle(len(s), func(i, j int) {
+		s[i], s[j] = s[j], s[i]
+	})
+	return s
+}
@@ -0,0 +1,248 @@
+package mapper
+
+import (
+	"fmt"
+	"github.com/nais/migrator/cluster"
+	"github.com/nais/migrator/fasit"
+	"github.com/nais/migrator/models/naisd"
+	"github.com/nais/migrator/models/naiserator"
+	"github.com/nais/migrator/report"
+	"path"
+	"sort"
+	"strings"
+)
+
+// ResourceContext describes the deployment a Fasit resource is converted for.
+type ResourceContext struct {
+	Deploy  naisd.Deploy
+	Cluster cluster.Cluster
+}
+
+// ResourceResult is what a resource handler contributes to the converted application.
+type ResourceResult struct {
+	Env    []naiserator.EnvVar
+	Files  []naiserator.FilesFrom
+	Mounts []naiserator.SecretPath
+	// External hosts the application needs outbound access to.
+	External []naiserator.AccessPolicyExternalRule
+	Report   report.Report
+}
+
+// ResourceHandler converts a single Fasit resource.
+type ResourceHandler func(ctx ResourceContext, resource fasit.NaisResource) ResourceResult
+
+// LiveEnvironment is the resource type of the environment of a live deployment. Its variables are kept as they are,
+// as the application reads them by these names, instead of being named like Fasit properties.
+const LiveEnvironment = "liveenvironment"
+
+// handlers are keyed by lower case Fasit resource type.
+// Resource types without a handler are converted by PropertiesHandler.
+var handlers = map[string]ResourceHandler{
+	"applicationproperties": PropertiesHandler,
+	"certificate":           certificateHandler,
+	"credential":            credentialHandler,
+	"datasource":            dataSourceHandler,
+	"ldap":                  PropertiesHandler,
+	LiveEnvironment:         liveEnvironmentHandler,
+	"loadbalancerconfig":    loadBalancerHandler,
+	"openam":                PropertiesHandler,
+	"queue":                 queueHandler,
+	"queuemanager":          queueManagerHandler,
+	"restservice":           PropertiesHandler,
+	"webserviceendpoint":    PropertiesHandler,
+}
+
+// RegisterResourceHandler registers the handler for a Fasit resource type, replacing any existing handler.
+// Resource types are matched case-insensitively.
+func RegisterResourceHandler(resourceType string, handler ResourceHandler) {
+	handlers[strings.ToLower(resourceType)] = handler
+}
+
+func handlerFor(resourceType string) ResourceHandler {
+	if handler, ok := handlers[strings.ToLower(resourceType)]; ok {
+		return handler
+	}
+	return PropertiesHandler
+}
+
+// convertResources runs every resource through its handler, and combines the results.
+// Environment variables are renamed as configured first, so that variables renamed into the same name
+// are resolved using the collision strategy like any other variables with the same name.
+func convertResources(ctx ResourceContext, resources []fasit.NaisResource, collisions CollisionStrategy, renames map[string]string) (ResourceResult, error) {
+	var combined ResourceResult
+	var origins []origin
+	var err error
+
+	for _, resource := range resources {
+		result := handlerFor(resource.ResourceType)(ctx, resource)
+		for _, env := range renameEnv(result.Env, renames) {
+			origins = append(origins, origin{env: env, resource: resource})
+		}
+		combined.Files = append(combined.Files, result.Files...)
+		combined.Mounts = append(combined.Mounts, result.Mounts...)
+		combined.External = append(combined.External, result.External...)
+		combined.Report.Merge(result.Report)
+	}
+
+	combined.Env, err = resolveCollisions(origins, collisions, &combined.Report)
+
+	return combined, err
+}
+
+// PropertiesHandler is the default handler. Properties become environment variables, and secrets are mounted from Vault.
+// When migrating to GCP, hosts found in property values that are only reachable on-premises are reported.
+func PropertiesHandler(ctx ResourceContext, resource fasit.NaisResource) ResourceResult {
+	var result ResourceResult
+
+	for _, key := range sortedKeys(resource.Properties) {
+		result.Env = append(result.Env, naiserator.EnvVar{
+			Name:  resource.ToEnvironmentVariable(key),
+			Value: resource.Properties[key],
+		})
+	}
+
+	result.Mounts = vaultSecrets(resource, &result.Report)
+	unreachableHosts(ctx, resource, &result.Report)
+
+	return result
+}
+
+// liveEnvironmentHandler converts the environment of a live deployment, keeping the variable names.
+func liveEnvironmentHandler(ctx ResourceContext, resource fasit.NaisResource) ResourceResult {
+	var result ResourceResult
+
+	for _, name := range sortedKeys(resource.Properties) {
+		result.Env = append(result.Env, naiserator.EnvVar{
+			Name:  name,
+			Value: resource.Properties[name],
+		})
+	}
+
+	unreachableHosts(ctx, resource, &result.Report)
+
+	return result
+}
+
+func loadBalancerHandler(ctx ResourceContext, resource fasit.NaisResource) ResourceResult {
+	// Load balancer configuration is converted into ingresses by fasitIngress.
+	return ResourceResult{}
+}
+
+// certificateHandler converts certificate resources. The NAV truststore is included in all Naiserator
+// deployments, while other certificate files must be provided as Kubernetes secrets.
+func certificateHandler(ctx ResourceContext, resource fasit.NaisResource) ResourceResult {
+	result := PropertiesHandler(ctx, resource)
+
+	if resource.Name == fasit.NavTruststoreFasitAlias {
+		for k := range resource.Certificates {
+			result.Report.Infof(source(resource), "Certificate in resource '%s' is automatically included in Naiserator deployments", k)
+		}
+		return result
+	}
+
+	if len(resource.Certificates) == 0 {
+		return result
+	}
+
+	mountPath := fmt.Sprintf("/var/run/secrets/nais.io/certificates/%s", resource.Name)
+	result.Files = append(result.Files, naiserator.FilesFrom{
+		Secret:    resource.Name,
+		MountPath: mountPath,
+	})
+
+	names := make([]string, 0, len(resource.Certificates))
+	for k := range resource.Certificates {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	// naisd exposed the location of each certificate file in an environment variable.
+	for _, k := range names {
+		result.Env = append(result.Env, naiserator.EnvVar{
+			Name:  resource.ToEnvironmentVariable(k),
+			Value: fmt.Sprintf("%s/%s", mountPath, k),
+		})
+		result.Report.Warnf(source(resource), "Certificate '%s' in resource '%s' must be stored in the Kubernetes secret '%s'; it is mounted under '%s'",
+			k, resource.Name, resource.Name, mountPath)
+	}
+
+	return result
+}
+
+// vaultSecrets mounts the Vault paths of the secrets in a resource.
+//
+// Each secret path ends with the key within a Vault KV secret, which becomes the file name in the mount.
+// Secrets stored in the same KV secret share a mount under /var/run/secrets/nais.io/<alias>. If the resource
+// has secrets in several KV secrets, each KV secret is mounted in a subdirectory named after it.
+func vaultSecrets(resource fasit.NaisResource, rep *report.Report) []naiserator.SecretPath {
+	var paths []naiserator.SecretPath
+	var kvPaths []string
+	files := make(map[string][]string)
+
+	for _, k := range sortedKeys(resource.Secret) {
+		secret := resource.Secret[k]
+		if len(secret) == 0 {
+			rep.Warnf(source(resource), "Skipping environment variable '%s' from secret '%s'", resource.ToEnvironmentVariable(k), resource.Name)
+			continue
+		}
+		kvPath, _ := path.Split(secret)
+		kvPath = path.Clean(kvPath)
+		if _, ok := files[kvPath]; !ok {
+			kvPaths = append(kvPaths, kvPath)
+		}
+		files[kvPath] = append(files[kvPath], k)
+	}
+
+	sort.Strings(kvPaths)
+	base := fmt.Sprintf("/var/run/secrets/nais.io/%s", resource.Name)
+	used := make(map[string]bool)
+
+	for i, kvPath := range kvPaths {
+		mountPath := base
+		if len(kvPaths) > 1 {
+			mountPath = path.Join(base, path.Base(kvPath))
+			if used[mountPath] {
+				mountPath = fmt.Sprintf("%s_%d", mountPath, i+1)
+			}
+		}
+		used[mountPath] = true
+
+		paths = append(paths, naiserator.SecretPath{
+			KvPath:    kvPath,
+			MountPath: mountPath,
+		})
+
+		for _, k := range files[kvPath] {
+			file := path.Join(mountPath, path.Base(resource.Secret[k]))
+			rep.Warnf(source(resource), "Secret in environment variable '%s' is now mounted from Vault as the file '%s'", resource.ToEnvironmentVariable(k), file)
+		}
+	}
+
+	return paths
+}
+
+// secretFile returns the file a secret is mounted as, given the mounts created by vaultSecrets.
+func secretFile(resource fasit.NaisResource, mounts []naiserator.SecretPath, key string) string {
+	kvPath, file := path.Split(resource.Secret[key])
+	for _, mount := range mounts {
+		if mount.KvPath == path.Clean(kvPath) {
+			return path.Join(mount.MountPath, file)
+		}
+	}
+	return ""
+}
+
+// source names a Fasit resource in findings.
+func source(resource fasit.NaisResource) string {
+	return fmt.Sprintf("fasit:%s", resource.Name)
+}
+
+// sortedKeys returns the keys of a map in sorted order, for use when the result must not depend on map iteration order.
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
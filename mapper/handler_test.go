@@ -0,0 +1,45 @@
+package mapper
+
+import (
+	"github.com/nais/migrator/cluster"
+	"github.com/nais/migrator/fasit"
+	"github.com/nais/migrator/models/naisd"
+	"github.com/nais/migrator/models/naiserator"
+	"github.com/nais/migrator/report"
+	"reflect"
+	"testing"
+)
+
+func TestLiveEnvironmentHandler(t *testing.T) {
+	resource := fasit.NaisResource{
+		Name:         "app",
+		ResourceType: LiveEnvironment,
+		Properties: map[string]string{
+			"spring.profiles.active": "prod",
+			"log_level":              "info",
+			"DB_URL":                 "jdbc:oracle:thin:@//db.preprod.local:1521/app",
+		},
+	}
+
+	result := handlerFor(resource.ResourceType)(ResourceContext{Deploy: naisd.Deploy{Application: "app"}}, resource)
+
+	// The names are kept as they are, unlike the names of Fasit properties.
+	expected := []naiserator.EnvVar{
+		{Name: "DB_URL", Value: "jdbc:oracle:thin:@//db.preprod.local:1521/app"},
+		{Name: "log_level", Value: "info"},
+		{Name: "spring.profiles.active", Value: "prod"},
+	}
+	if !reflect.DeepEqual(result.Env, expected) {
+		t.Errorf("converted variables differ:\n got: %v\nwant: %v", result.Env, expected)
+	}
+	if len(result.Report.Findings) > 0 {
+		t.Errorf("unexpected findings: %v", result.Report.Findings)
+	}
+
+	// Hosts only reachable on-premises are reported when migrating to GCP, as for other resources.
+	ctx := ResourceContext{Deploy: naisd.Deploy{Application: "app"}, Cluster: cluster.Cluster{Name: "dev-gcp", GCP: true}}
+	result = handlerFor(resource.ResourceType)(ctx, resource)
+	if !reported(result.Report, report.Error, "Property 'DB_URL' points at on-premises host 'db.preprod.local'") {
+		t.Errorf("expected the on-premises host to be reported, got %v", result.Report.Findings)
+	}
+}
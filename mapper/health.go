@@ -0,0 +1,113 @@
+package mapper
+
+import (
+	"fmt"
+	"github.com/nais/migrator/models/naisd"
+	"github.com/nais/migrator/models/naiserator"
+	"github.com/nais/migrator/report"
+	"strconv"
+	"strings"
+)
+
+// naisdPortName is the name naisd gave the application's container port.
+const naisdPortName = "http"
+
+// naiseratorProbe holds the values Naiserator, through Kubernetes, uses for probe fields that are left out.
+var naiseratorProbe = naiserator.Probe{
+	InitialDelay:     0,
+	PeriodSeconds:    10,
+	FailureThreshold: 3,
+	Timeout:          1,
+}
+
+// port returns the application port naisd used.
+func port(manifest naisd.NaisManifest) int {
+	if manifest.Port == 0 {
+		return naisd.DefaultManifest("").Port
+	}
+	return manifest.Port
+}
+
+// orDefault mirrors naisd, which treated zero values as unset and replaced them with its defaults.
+func orDefault(value, def int) int {
+	if value == 0 {
+		return def
+	}
+	return value
+}
+
+// probeConvert converts a naisd probe into a probe with the values naisd actually used.
+// All values are set explicitly, so the application is probed as before. Values that differ from what Naiserator
+// would use if left out are noted, explaining why they are in the output; there is nothing for the team to do.
+// A probe without a path is left out, as there is nothing to probe.
+func probeConvert(name string, manifest naisd.NaisManifest, probe, defaults naisd.Probe, rep *report.Report) naiserator.Probe {
+	path := strings.TrimSpace(probe.Path)
+	if len(path) == 0 {
+		rep.Warnf("healthcheck", "The %s probe has no path and has been left out.", name)
+		return naiserator.Probe{}
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	converted := naiserator.Probe{
+		Path:             path,
+		Port:             port(manifest),
+		InitialDelay:     orDefault(probe.InitialDelay, defaults.InitialDelay),
+		PeriodSeconds:    orDefault(probe.PeriodSeconds, defaults.PeriodSeconds),
+		FailureThreshold: orDefault(probe.FailureThreshold, defaults.FailureThreshold),
+		Timeout:          orDefault(probe.Timeout, defaults.Timeout),
+	}
+
+	var differences []string
+	compare := func(field string, naisdValue, naiseratorValue int) {
+		if naisdValue != naiseratorValue {
+			differences = append(differences, fmt.Sprintf("%s %d instead of %d", field, naisdValue, naiseratorValue))
+		}
+	}
+	compare("initialDelay", converted.InitialDelay, naiseratorProbe.InitialDelay)
+	compare("periodSeconds", converted.PeriodSeconds, naiseratorProbe.PeriodSeconds)
+	compare("failureThreshold", converted.FailureThreshold, naiseratorProbe.FailureThreshold)
+	compare("timeout", converted.Timeout, naiseratorProbe.Timeout)
+
+	if len(differences) > 0 {
+		rep.Infof("healthcheck", "Naiserator defaults differ from naisd for the %s probe, so naisd's values are set explicitly: %s.", name, strings.Join(differences, ", "))
+	}
+
+	return converted
+}
+
+// prometheusConvert converts the Prometheus configuration. naisd referred to the application port by name,
+// which is translated into the port number. Scraping configuration is left out if scraping is disabled.
+func prometheusConvert(manifest naisd.NaisManifest, rep *report.Report) naiserator.PrometheusConfig {
+	config := manifest.Prometheus
+	if !config.Enabled {
+		return naiserator.PrometheusConfig{}
+	}
+
+	defaults := naisd.DefaultManifest("").Prometheus
+
+	metricsPort := strings.TrimSpace(config.Port)
+	switch {
+	case len(metricsPort) == 0, metricsPort == naisdPortName:
+		metricsPort = strconv.Itoa(port(manifest))
+	default:
+		if _, err := strconv.Atoi(metricsPort); err != nil {
+			rep.Warnf("prometheus", "Prometheus port '%s' is not a port number; set the port Prometheus should scrape.", metricsPort)
+		}
+	}
+
+	path := strings.TrimSpace(config.Path)
+	if len(path) == 0 {
+		path = defaults.Path
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	return naiserator.PrometheusConfig{
+		Enabled: true,
+		Port:    metricsPort,
+		Path:    path,
+	}
+}
@@ -0,0 +1,125 @@
+package mapper
+
+import (
+	"github.com/nais/migrator/models/naisd"
+	"github.com/nais/migrator/models/naiserator"
+	"github.com/nais/migrator/report"
+	"testing"
+)
+
+func TestProbeConvert(t *testing.T) {
+	defaults := naisd.DefaultManifest("app").Healthcheck.Liveness
+
+	tests := []struct {
+		name     string
+		port     int
+		probe    naisd.Probe
+		expected naiserator.Probe
+		// finding is part of the expected finding, if any.
+		finding  string
+		severity report.Severity
+	}{
+		{
+			name:     "naisd defaults are set explicitly",
+			probe:    naisd.Probe{Path: "isalive"},
+			expected: naiserator.Probe{Path: "/isalive", Port: 8080, InitialDelay: 20, PeriodSeconds: 10, FailureThreshold: 3, Timeout: 1},
+			finding:  "Naiserator defaults differ from naisd for the liveness probe, so naisd's values are set explicitly: initialDelay 20 instead of 0.",
+			severity: report.Info,
+		},
+		{
+			name:     "zero values take naisd's defaults and the path is trimmed",
+			port:     9090,
+			probe:    naisd.Probe{Path: " /internal/isalive ", InitialDelay: 0, PeriodSeconds: 10, FailureThreshold: 3, Timeout: 1},
+			expected: naiserator.Probe{Path: "/internal/isalive", Port: 9090, InitialDelay: 20, PeriodSeconds: 10, FailureThreshold: 3, Timeout: 1},
+			finding:  "initialDelay 20 instead of 0.",
+			severity: report.Info,
+		},
+		{
+			name:     "every differing value is listed",
+			probe:    naisd.Probe{Path: "isalive", InitialDelay: 60, PeriodSeconds: 5, FailureThreshold: 10, Timeout: 3},
+			expected: naiserator.Probe{Path: "/isalive", Port: 8080, InitialDelay: 60, PeriodSeconds: 5, FailureThreshold: 10, Timeout: 3},
+			finding:  "initialDelay 60 instead of 0, periodSeconds 5 instead of 10, failureThreshold 10 instead of 3, timeout 3 instead of 1.",
+			severity: report.Info,
+		},
+		{
+			name:     "no path",
+			probe:    naisd.Probe{Path: " ", InitialDelay: 20},
+			expected: naiserator.Probe{},
+			finding:  "The liveness probe has no path and has been left out.",
+			severity: report.Warning,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var rep report.Report
+			manifest := naisd.NaisManifest{Port: test.port}
+			converted := probeConvert("liveness", manifest, test.probe, defaults, &rep)
+
+			if converted != test.expected {
+				t.Errorf("converted probe differs:\n got: %+v\nwant: %+v", converted, test.expected)
+			}
+			if !reported(rep, test.severity, test.finding) {
+				t.Errorf("expected a finding containing '%s', got %v", test.finding, rep.Findings)
+			}
+		})
+	}
+}
+
+func TestPrometheusConvert(t *testing.T) {
+	tests := []struct {
+		name       string
+		port       int
+		prometheus naisd.PrometheusConfig
+		expected   naiserator.PrometheusConfig
+		// warning is part of the expected warning, if any.
+		warning string
+	}{
+		{
+			name:       "disabled",
+			prometheus: naisd.PrometheusConfig{Enabled: false, Port: "http", Path: "/metrics"},
+			expected:   naiserator.PrometheusConfig{},
+		},
+		{
+			name:       "named port is the application port",
+			port:       9090,
+			prometheus: naisd.PrometheusConfig{Enabled: true, Port: "http", Path: "/metrics"},
+			expected:   naiserator.PrometheusConfig{Enabled: true, Port: "9090", Path: "/metrics"},
+		},
+		{
+			name:       "no port or path",
+			prometheus: naisd.PrometheusConfig{Enabled: true},
+			expected:   naiserator.PrometheusConfig{Enabled: true, Port: "8080", Path: "/metrics"},
+		},
+		{
+			name:       "separate metrics port and relative path",
+			prometheus: naisd.PrometheusConfig{Enabled: true, Port: "8081", Path: "internal/prometheus"},
+			expected:   naiserator.PrometheusConfig{Enabled: true, Port: "8081", Path: "/internal/prometheus"},
+		},
+		{
+			name:       "port that is not a number",
+			prometheus: naisd.PrometheusConfig{Enabled: true, Port: "metrics", Path: "/metrics"},
+			expected:   naiserator.PrometheusConfig{Enabled: true, Port: "metrics", Path: "/metrics"},
+			warning:    "Prometheus port 'metrics' is not a port number",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var rep report.Report
+			manifest := naisd.NaisManifest{Port: test.port, Prometheus: test.prometheus}
+			converted := prometheusConvert(manifest, &rep)
+
+			if converted != test.expected {
+				t.Errorf("converted configuration differs:\n got: %+v\nwant: %+v", converted, test.expected)
+			}
+			if len(test.warning) == 0 {
+				if len(rep.Findings) > 0 {
+					t.Errorf("unexpected findings: %v", rep.Findings)
+				}
+			} else if !reported(rep, report.Warning, test.warning) {
+				t.Errorf("expected a warning containing '%s', got %v", test.warning, rep.Findings)
+			}
+		})
+	}
+}
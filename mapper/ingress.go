@@ -0,0 +1,103 @@
+package mapper
+
+import (
+	"fmt"
+	"github.com/nais/migrator/cluster"
+	"github.com/nais/migrator/report"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// normalizeIngress returns the canonical form of an ingress URL: https, a lower case host without the default port,
+// and a path without a trailing slash. URLs without a scheme are taken to be https.
+func normalizeIngress(ingress string) (*url.URL, error) {
+	ingress = strings.TrimSpace(ingress)
+	if !strings.Contains(ingress, "://") {
+		ingress = "https://" + ingress
+	}
+
+	u, err := url.Parse(ingress)
+	if err != nil {
+		return nil, err
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if len(host) == 0 {
+		return nil, fmt.Errorf("no host")
+	}
+	if port := u.Port(); len(port) > 0 && port != "443" && port != "80" {
+		return nil, fmt.Errorf("ingresses are served on the default port, not %s", port)
+	}
+
+	normalized := &url.URL{Scheme: "https", Host: host}
+	if p := strings.Trim(u.Path, "/ "); len(p) > 0 {
+		normalized.Path = path.Clean("/" + p)
+	}
+
+	return normalized, nil
+}
+
+// suggestIngress moves an ingress to the suggested domain of the cluster, keeping the first label of the host.
+func suggestIngress(u *url.URL, target cluster.Cluster) *url.URL {
+	label := strings.SplitN(u.Hostname(), ".", 2)[0]
+	suggestion := *u
+	suggestion.Host = label + "." + target.SuggestDomain(u.Hostname())
+	return &suggestion
+}
+
+// processIngresses normalizes and deduplicates ingresses, and checks that their hosts belong to the cluster's domains.
+// Ingresses outside the domains are rewritten to the suggested domain if rewrite is set. Otherwise, the replacement
+// is suggested in the report; the ingress is kept on-premises and removed on GCP, where it can not be served.
+func processIngresses(ingresses []string, target cluster.Cluster, rewrite bool, rep *report.Report) []string {
+	var processed []string
+	checked := make(map[string]bool, len(ingresses))
+	seen := make(map[string]bool, len(ingresses))
+
+	add := func(ingress string) {
+		if seen[ingress] {
+			return
+		}
+		seen[ingress] = true
+		processed = append(processed, ingress)
+	}
+
+	for _, ingress := range ingresses {
+		u, err := normalizeIngress(ingress)
+		if err != nil {
+			rep.Warnf("ingress", "Ingress '%s' is not a valid ingress and has been removed: %s", ingress, err)
+			continue
+		}
+		if u.String() != ingress {
+			rep.Infof("ingress", "Ingress '%s' has been normalized to '%s'", ingress, u)
+		}
+		if checked[u.String()] {
+			continue
+		}
+		checked[u.String()] = true
+
+		if target.AllowsHost(u.Hostname()) {
+			add(u.String())
+			continue
+		}
+
+		domains := strings.Join(target.IngressDomains, ", ")
+		suggestion := suggestIngress(u, target)
+
+		switch {
+		case rewrite:
+			rep.Warnf("ingress", "Ingress '%s' is outside the domains of cluster '%s' and has been rewritten to '%s'; allowed domains are %s",
+				u, target.Name, suggestion, domains)
+			add(suggestion.String())
+		case target.GCP:
+			rep.Warnf("ingress", "Ingress '%s' can not be served from cluster '%s' and has been removed; use '%s' instead, or another host under %s",
+				u, target.Name, suggestion, domains)
+		default:
+			rep.Warnf("ingress", "Ingress '%s' is outside the domains of cluster '%s'; use '%s' instead, or another host under %s",
+				u, target.Name, suggestion, domains)
+			add(u.String())
+		}
+	}
+
+	return processed
+}
@@ -0,0 +1,155 @@
+package mapper
+
+import (
+	"github.com/nais/migrator/cluster"
+	"github.com/nais/migrator/models/naisd"
+	"github.com/nais/migrator/report"
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeIngress(t *testing.T) {
+	tests := []struct {
+		ingress    string
+		normalized string
+		// error is set if the ingress is expected to be invalid.
+		error bool
+	}{
+		{ingress: "https://app.dev.adeo.no", normalized: "https://app.dev.adeo.no"},
+		{ingress: "app.dev.adeo.no/app", normalized: "https://app.dev.adeo.no/app"},
+		{ingress: "http://App.Dev.Adeo.NO/app/", normalized: "https://app.dev.adeo.no/app"},
+		{ingress: " https://app.dev.adeo.no./ ", normalized: "https://app.dev.adeo.no"},
+		{ingress: "https://app.dev.adeo.no:443/app//api/", normalized: "https://app.dev.adeo.no/app/api"},
+		{ingress: "http://app.dev.adeo.no:80", normalized: "https://app.dev.adeo.no"},
+		{ingress: "https://app.dev.adeo.no:8443/app", error: true},
+		{ingress: "https:///app", error: true},
+	}
+
+	for _, test := range tests {
+		u, err := normalizeIngress(test.ingress)
+		if test.error {
+			if err == nil {
+				t.Errorf("expected '%s' to be invalid, got %s", test.ingress, u)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("unexpected error for '%s': %s", test.ingress, err)
+			continue
+		}
+		if u.String() != test.normalized {
+			t.Errorf("'%s' is normalized to '%s', want '%s'", test.ingress, u, test.normalized)
+		}
+	}
+}
+
+func TestProcessIngresses(t *testing.T) {
+	devFss, err := cluster.Lookup(naisd.ZONE_FSS, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	devGcp, err := cluster.Lookup(cluster.ZONE_GCP, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		ingresses []string
+		target    cluster.Cluster
+		rewrite   bool
+		processed []string
+		// findings are parts of the expected findings.
+		findings map[report.Severity][]string
+	}{
+		{
+			name:      "allowed ingresses are kept",
+			ingresses: []string{"https://app.nais.preprod.local", "https://app.dev.adeo.no/app"},
+			target:    devFss,
+			processed: []string{"https://app.nais.preprod.local", "https://app.dev.adeo.no/app"},
+		},
+		{
+			name:      "upper case hosts and trailing slashes are normalized and deduplicated",
+			ingresses: []string{"https://APP.dev.adeo.no/app/", "https://app.dev.adeo.no/app", "app.dev.adeo.no/app"},
+			target:    devFss,
+			processed: []string{"https://app.dev.adeo.no/app"},
+			findings: map[report.Severity][]string{
+				report.Info: {
+					"Ingress 'https://APP.dev.adeo.no/app/' has been normalized to 'https://app.dev.adeo.no/app'",
+					"Ingress 'app.dev.adeo.no/app' has been normalized to 'https://app.dev.adeo.no/app'",
+				},
+			},
+		},
+		{
+			name:      "invalid ingresses are removed",
+			ingresses: []string{"https://app.dev.adeo.no:8443", "https://app.dev.adeo.no"},
+			target:    devFss,
+			processed: []string{"https://app.dev.adeo.no"},
+			findings: map[report.Severity][]string{
+				report.Warning: {"Ingress 'https://app.dev.adeo.no:8443' is not a valid ingress and has been removed"},
+			},
+		},
+		{
+			name:      "ingresses outside the domains are kept on-premises",
+			ingresses: []string{"https://app-q1.adeo.no/app"},
+			target:    devFss,
+			processed: []string{"https://app-q1.adeo.no/app"},
+			findings: map[report.Severity][]string{
+				report.Warning: {"Ingress 'https://app-q1.adeo.no/app' is outside the domains of cluster 'dev-fss'; use 'https://app-q1.dev.adeo.no/app' instead"},
+			},
+		},
+		{
+			name:      "ingresses outside the domains are removed on GCP",
+			ingresses: []string{"https://app.nais.preprod.local", "https://app.dev.nav.no"},
+			target:    devGcp,
+			processed: []string{"https://app.dev.nav.no"},
+			findings: map[report.Severity][]string{
+				report.Warning: {"Ingress 'https://app.nais.preprod.local' can not be served from cluster 'dev-gcp' and has been removed"},
+			},
+		},
+		{
+			name:      "rewritten ingresses are deduplicated",
+			ingresses: []string{"https://APP-Q1.adeo.no/app/", "https://app-q1.dev.adeo.no/app"},
+			target:    devFss,
+			rewrite:   true,
+			processed: []string{"https://app-q1.dev.adeo.no/app"},
+			findings: map[report.Severity][]string{
+				report.Info:    {"Ingress 'https://APP-Q1.adeo.no/app/' has been normalized to 'https://app-q1.adeo.no/app'"},
+				report.Warning: {"Ingress 'https://app-q1.adeo.no/app' is outside the domains of cluster 'dev-fss' and has been rewritten to 'https://app-q1.dev.adeo.no/app'"},
+			},
+		},
+		{
+			name:      "internal hosts are rewritten to an internal domain on GCP",
+			ingresses: []string{"https://app.nais.preprod.local/app"},
+			target:    devGcp,
+			rewrite:   true,
+			processed: []string{"https://app.dev.intern.nav.no/app"},
+			findings: map[report.Severity][]string{
+				report.Warning: {"has been rewritten to 'https://app.dev.intern.nav.no/app'"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var rep report.Report
+			processed := processIngresses(test.ingresses, test.target, test.rewrite, &rep)
+
+			if !reflect.DeepEqual(processed, test.processed) {
+				t.Errorf("processed ingresses differ:\n got: %v\nwant: %v", processed, test.processed)
+			}
+			count := 0
+			for severity, messages := range test.findings {
+				for _, message := range messages {
+					if !reported(rep, severity, message) {
+						t.Errorf("expected a finding containing '%s', got %v", message, rep.Findings)
+					}
+				}
+				count += len(messages)
+			}
+			if len(rep.Findings) != count {
+				t.Errorf("got %d findings, want %d: %v", len(rep.Findings), count, rep.Findings)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package mapper
+
+import (
+	"github.com/nais/migrator/models/naisd"
+	"github.com/nais/migrator/models/naiserator"
+	"github.com/nais/migrator/report"
+	"strings"
+)
+
+// logformats translates naisd log formats into the log formats Naiserator accepts.
+// An empty translation means Naiserator handles the format without configuration.
+var logformats = map[string]string{
+	"accesslog":                        "accesslog",
+	"accesslog_with_processing_time":   "accesslog_with_processing_time",
+	"accesslog_with_referer_useragent": "accesslog_with_referer_useragent",
+	"capnslog":                         "capnslog",
+	"glog":                             "glog",
+	"gokit":                            "gokit",
+	"influxdb":                         "influxdb",
+	"json":                             "",
+	"log15":                            "log15",
+	"logrus":                           "logrus",
+	"redis":                            "redis",
+	"simple":                           "simple",
+}
+
+// logtransforms translates naisd log transforms into the log transforms Naiserator accepts.
+var logtransforms = map[string]string{
+	"dns_loglevel":  "dns_loglevel",
+	"http_loglevel": "http_loglevel",
+}
+
+// logConvert translates the log configuration of the manifest. Log configuration without
+// an equivalent in Naiserator is left out and reported.
+func logConvert(manifest naisd.NaisManifest, secureLogs bool, rep *report.Report) (logformat, logtransform string, secure naiserator.SecureLogs) {
+	if format := strings.ToLower(strings.TrimSpace(manifest.Logformat)); len(format) > 0 {
+		translated, ok := logformats[format]
+		switch {
+		case !ok:
+			rep.Warnf("logging", "Log format '%s' has no equivalent in Naiserator and has been left out; logs are parsed as JSON or plain text.", manifest.Logformat)
+		case len(translated) == 0:
+			rep.Infof("logging", "Log format '%s' needs no configuration in Naiserator and has been left out.", manifest.Logformat)
+		}
+		logformat = translated
+	}
+
+	if transform := strings.ToLower(strings.TrimSpace(manifest.Logtransform)); len(transform) > 0 {
+		translated, ok := logtransforms[transform]
+		if !ok {
+			rep.Warnf("logging", "Log transform '%s' has no equivalent in Naiserator and has been left out.", manifest.Logtransform)
+		}
+		logtransform = translated
+	}
+
+	if secureLogs {
+		secure.Enabled = true
+		rep.Infof("logging", "Secure logs are enabled; log lines written to files in /secure-logs are shipped to the secure log.")
+	}
+
+	return logformat, logtransform, secure
+}
@@ -0,0 +1,88 @@
+package mapper
+
+import (
+	"github.com/nais/migrator/models/naisd"
+	"github.com/nais/migrator/report"
+	"testing"
+)
+
+func TestLogConvert(t *testing.T) {
+	tests := []struct {
+		name         string
+		manifest     naisd.NaisManifest
+		secureLogs   bool
+		logformat    string
+		logtransform string
+		// finding is part of the expected finding, if any.
+		finding  string
+		severity report.Severity
+	}{
+		{
+			name: "no log configuration",
+		},
+		{
+			name:      "known format",
+			manifest:  naisd.NaisManifest{Logformat: "accesslog"},
+			logformat: "accesslog",
+		},
+		{
+			name:      "format in another case",
+			manifest:  naisd.NaisManifest{Logformat: " Glog "},
+			logformat: "glog",
+		},
+		{
+			name:     "json needs no configuration",
+			manifest: naisd.NaisManifest{Logformat: "json"},
+			finding:  "Log format 'json' needs no configuration in Naiserator and has been left out.",
+			severity: report.Info,
+		},
+		{
+			name:     "unknown format",
+			manifest: naisd.NaisManifest{Logformat: "log4j"},
+			finding:  "Log format 'log4j' has no equivalent in Naiserator and has been left out",
+			severity: report.Warning,
+		},
+		{
+			name:         "known transform",
+			manifest:     naisd.NaisManifest{Logformat: "accesslog", Logtransform: "HTTP_LOGLEVEL"},
+			logformat:    "accesslog",
+			logtransform: "http_loglevel",
+		},
+		{
+			name:     "unknown transform",
+			manifest: naisd.NaisManifest{Logtransform: "drop_debug"},
+			finding:  "Log transform 'drop_debug' has no equivalent in Naiserator and has been left out.",
+			severity: report.Warning,
+		},
+		{
+			name:       "secure logs",
+			secureLogs: true,
+			finding:    "Secure logs are enabled",
+			severity:   report.Info,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var rep report.Report
+			logformat, logtransform, secure := logConvert(test.manifest, test.secureLogs, &rep)
+
+			if logformat != test.logformat {
+				t.Errorf("got log format '%s', want '%s'", logformat, test.logformat)
+			}
+			if logtransform != test.logtransform {
+				t.Errorf("got log transform '%s', want '%s'", logtransform, test.logtransform)
+			}
+			if secure.Enabled != test.secureLogs {
+				t.Errorf("got secure logs %v, want %v", secure.Enabled, test.secureLogs)
+			}
+			if len(test.finding) == 0 {
+				if len(rep.Findings) > 0 {
+					t.Errorf("unexpected findings: %v", rep.Findings)
+				}
+			} else if !reported(rep, test.severity, test.finding) {
+				t.Errorf("expected a finding containing '%s', got %v", test.finding, rep.Findings)
+			}
+		})
+	}
+}
@@ -3,32 +3,30 @@ package mapper
 
 import (
 	"fmt"
+	"github.com/nais/migrator/cluster"
+	"github.com/nais/migrator/config"
 	"github.com/nais/migrator/fasit"
 	"github.com/nais/migrator/models/naisd"
 	"github.com/nais/migrator/models/naiserator"
+	"github.com/nais/migrator/report"
 	log "github.com/sirupsen/logrus"
 	"net/url"
+	"sort"
 )
 
-func autoIngress(deploy naisd.Deploy) string {
-	const format = "https://%s.nais.%s"
-	var domain string
-
-	if deploy.Zone == naisd.ZONE_FSS {
-		if deploy.FasitEnvironment == naisd.ENVIRONMENT_P {
-			domain = "adeo.no"
-		} else {
-			domain = "preprod.local"
-		}
-	} else {
-		if deploy.FasitEnvironment == naisd.ENVIRONMENT_P {
-			domain = "oera.no"
-		} else {
-			domain = "oera-q.local"
-		}
-	}
+// Options control how Convert builds the application.
+type Options struct {
+	// Overrides from the configuration file, applied on top of the converted application.
+	Overrides config.Overrides
+	// Target selects where the application will run. Defaults to the on-premises cluster serving the zone.
+	Target Target
+	// Cluster the application is migrated to. If empty, it is resolved from the zone, environment and target.
+	Cluster cluster.Cluster
+}
 
-	return fmt.Sprintf(format, deploy.Application, domain)
+func autoIngress(deploy naisd.Deploy, target cluster.Cluster) string {
+	const format = "https://%s.%s"
+	return fmt.Sprintf(format, deploy.Application, target.AutoIngressDomain())
 }
 
 func fasitIngress(resources []fasit.NaisResource) []string {
@@ -48,146 +46,223 @@ func fasitIngress(resources []fasit.NaisResource) []string {
 	return ingresses
 }
 
-func fasitEnv(resources []fasit.NaisResource) []naiserator.EnvVar {
-	var vars []naiserator.EnvVar
+func replicaConvert(replicas naisd.Replicas) naiserator.Replicas {
+	return naiserator.Replicas{
+		Min:                    replicas.Min,
+		Max:                    replicas.Max,
+		CpuThresholdPercentage: replicas.CpuThresholdPercentage,
+	}
+}
 
-	// TODO: REDIS_HOST with redis:true
+func resourceConvert(config naisd.ResourceList) naiserator.ResourceSpec {
+	return naiserator.ResourceSpec{
+		Cpu:    config.Cpu,
+		Memory: config.Memory,
+	}
+}
 
-	for _, resource := range resources {
-		for k, v := range resource.Secret {
-			if len(v) == 0 {
-				log.Warnf("Skipping environment variable '%s' from secret '%s'", resource.ToEnvironmentVariable(k), resource.Name)
-			}
-		}
-		for k := range resource.Certificates {
-			log.Warnf("Skipping certificate '%s' in resource '%s'", k, resource.Name)
-			if resource.Name == "nav_truststore" {
-				log.Infof("Certificate in resource '%s' is automatically included in Naiserator deployments", k)
-			}
-		}
-		if resource.ResourceType == "loadbalancerconfig" {
-			continue
-		}
-		for key, val := range resource.Properties {
-			vars = append(vars, naiserator.EnvVar{
-				Name:  resource.ToEnvironmentVariable(key),
-				Value: val,
-			})
-		}
+// vaultConvert enables Vault if the manifest asks for it or secrets are mounted from Vault.
+// The sidecar, which keeps the Vault token renewed, requires Vault to be enabled.
+func vaultConvert(manifest naisd.NaisManifest, mounts []naiserator.SecretPath, rep *report.Report) naiserator.Vault {
+	vault := naiserator.Vault{
+		Enabled: manifest.Vault.Enabled || manifest.Secrets || len(mounts) > 0,
+		Sidecar: manifest.Vault.Sidecar,
+		Mounts:  mounts,
 	}
 
-	return vars
+	if vault.Sidecar && !vault.Enabled {
+		rep.Infof("vault", "Vault has been enabled, as the Vault sidecar is enabled.")
+		vault.Enabled = true
+	}
+
+	return vault
 }
 
-func fasitVaultSecrets(resources []fasit.NaisResource) []naiserator.SecretPath {
-	var paths []naiserator.SecretPath
+func dropResources(resources []fasit.NaisResource, aliases []string) []fasit.NaisResource {
+	if len(aliases) == 0 {
+		return resources
+	}
+
+	drop := make(map[string]bool, len(aliases))
+	for _, alias := range aliases {
+		drop[alias] = true
+	}
 
+	kept := make([]fasit.NaisResource, 0, len(resources))
 	for _, resource := range resources {
-		for k, secret := range resource.Secret {
-			if len(secret) == 0 {
-				continue
-			}
-			path := naiserator.SecretPath{
-				KvPath:    secret,
-				MountPath: fmt.Sprintf("/var/run/secrets/nais.io/%s", resource.Name),
-			}
-			paths = append(paths, path)
-			log.Warnf("Secret in environment variable '%s' is now mounted from Vault under the path '%s'", resource.ToEnvironmentVariable(k), path.MountPath)
+		if drop[resource.Name] {
+			log.Infof("Dropping Fasit resource '%s' as instructed by configuration", resource.Name)
+			continue
 		}
+		kept = append(kept, resource)
 	}
 
-	return paths
+	return kept
 }
 
-func probeConvert(manifest naisd.NaisManifest, probe naisd.Probe) naiserator.Probe {
-	return naiserator.Probe{
-		Port:             manifest.Port,
-		Path:             probe.Path,
-		Timeout:          probe.Timeout,
-		FailureThreshold: probe.FailureThreshold,
-		PeriodSeconds:    probe.PeriodSeconds,
-		InitialDelay:     probe.InitialDelay,
+func renameEnv(vars []naiserator.EnvVar, renames map[string]string) []naiserator.EnvVar {
+	for i := range vars {
+		if name, ok := renames[vars[i].Name]; ok {
+			log.Infof("Renaming environment variable '%s' to '%s' as instructed by configuration", vars[i].Name, name)
+			vars[i].Name = name
+		}
 	}
+	return vars
 }
 
-func prometheusConvert(config naisd.PrometheusConfig) naiserator.PrometheusConfig {
-	return naiserator.PrometheusConfig{
-		Path:    config.Path,
-		Port:    config.Port,
-		Enabled: config.Enabled,
-	}
+// sortResources orders resources by alias and type, so that the output does not depend on the order
+// resources are listed in the manifest or returned from Fasit. Environment variables are grouped by resource in this order.
+func sortResources(resources []fasit.NaisResource) []fasit.NaisResource {
+	sorted := append([]fasit.NaisResource{}, resources...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Name != sorted[j].Name {
+			return sorted[i].Name < sorted[j].Name
+		}
+		return sorted[i].ResourceType < sorted[j].ResourceType
+	})
+	return sorted
 }
 
-func replicaConvert(replicas naisd.Replicas) naiserator.Replicas {
-	return naiserator.Replicas{
-		Min:                    replicas.Min,
-		Max:                    replicas.Max,
-		CpuThresholdPercentage: replicas.CpuThresholdPercentage,
+// namespaceAndTeam decides which namespace the application is deployed to, and which team owns it.
+//
+// The namespace is taken from the deployment request, then from configuration, and finally derived from the team,
+// as team namespaces are the norm with Naiserator. If the team is unknown, it is derived from an explicitly chosen namespace.
+func namespaceAndTeam(manifest naisd.NaisManifest, deploy naisd.Deploy, overrides config.Overrides, rep *report.Report) (string, string, error) {
+	namespace := deploy.Namespace
+	if len(namespace) == 0 {
+		namespace = overrides.Namespace
 	}
-}
 
-func resourceConvert(config naisd.ResourceList) naiserator.ResourceSpec {
-	return naiserator.ResourceSpec{
-		Cpu:    config.Cpu,
-		Memory: config.Memory,
+	team := manifest.Team
+	if label, ok := overrides.Labels["team"]; ok {
+		team = label
 	}
+
+	if len(team) == 0 && len(namespace) > 0 && namespace != naisd.NAMESPACE_DEFAULT {
+		rep.Infof("team", "Team is not set; using namespace '%s' as team", namespace)
+		team = namespace
+	}
+
+	if len(team) == 0 {
+		return "", "", fmt.Errorf("team label would be empty; set 'team' in the NAIS manifest or a 'team' label in the configuration file")
+	}
+
+	if len(namespace) == 0 {
+		rep.Infof("namespace", "Namespace is not set; using team namespace '%s'", team)
+		namespace = team
+	}
+
+	return namespace, team, nil
 }
 
 // Convert from naisd manifest to Naiserator application Kubernetes resource.
-func Convert(manifest naisd.NaisManifest, deploy naisd.Deploy, resources []fasit.NaisResource) naiserator.Application {
+// Findings that need the attention of the team are returned in the report.
+func Convert(manifest naisd.NaisManifest, deploy naisd.Deploy, resources []fasit.NaisResource, options Options) (naiserator.Application, report.Report, error) {
 	var ingresses []string
+	var accessPolicy naiserator.AccessPolicy
+	var err error
+	var team string
+	var rep report.Report
+
+	overrides := options.Overrides
+	resources = sortResources(dropResources(resources, overrides.DropResources))
+
+	deploy.Namespace, team, err = namespaceAndTeam(manifest, deploy, overrides, &rep)
+	if err != nil {
+		return naiserator.Application{}, rep, err
+	}
+
+	target := options.Cluster
+	if len(target.Name) == 0 {
+		target, err = ResolveCluster(deploy, options.Target)
+		if err != nil {
+			return naiserator.Application{}, rep, err
+		}
+	}
+
+	webproxy := manifest.Webproxy
+	if webproxy && !target.Webproxy {
+		rep.Warnf("webproxy", "Webproxy is not available in cluster '%s' and has been disabled.", target.Name)
+		webproxy = false
+	}
 
 	if !manifest.Ingress.Disabled {
-		ingresses = append(ingresses, autoIngress(deploy))
+		ingresses = append(ingresses, autoIngress(deploy, target))
 		ingresses = append(ingresses, fasitIngress(resources)...)
 	}
 
 	// TODO: fix automatically by creating another Application spec?
 	if manifest.Redis.Enabled {
-		log.Warn("Automatic Redis setup is unsupported with Naiserator.")
+		rep.Warnf("redis", "Automatic Redis setup is unsupported with Naiserator.")
 	}
 
 	// TODO: fix automatically by creating an Alert spec?
 	if len(manifest.Alerts) > 0 {
-		log.Warn("Alerts must be configured using the Alert resource.")
+		rep.Warnf("alerts", "Alerts must be configured using the Alert resource.")
+	}
+
+	converted, err := convertResources(ResourceContext{Deploy: deploy, Cluster: target}, resources, CollisionStrategy(overrides.EnvCollisions), overrides.RenameEnv)
+	rep.Merge(converted.Report)
+	if err != nil {
+		return naiserator.Application{}, rep, err
+	}
+	secretPaths := converted.Mounts
+
+	accessPolicy.Outbound.External = outboundRules(converted.External, &rep)
+	if manifest.Istio.Enabled {
+		accessPolicy.Inbound = istioInbound(deploy.Namespace, &rep)
 	}
 
-	secretPaths := fasitVaultSecrets(resources)
+	if target.GCP {
+		secretPaths = gcpSecrets(secretPaths, target, &rep)
+	}
+	ingresses = processIngresses(append(ingresses, overrides.Ingresses...), target, overrides.RewriteIngresses, &rep)
+
 	if len(secretPaths) > 0 {
-		zonePrefix := "preprod"
-		if deploy.FasitEnvironment == naisd.ENVIRONMENT_P {
-			zonePrefix = "prod"
-		}
-		defPath := "/kv/%s/%s/%s/%s"
-		defPath = fmt.Sprintf(defPath, zonePrefix, deploy.Zone, deploy.Application, deploy.Namespace)
+		defPath := "%s/%s/%s"
+		defPath = fmt.Sprintf(defPath, target.VaultKvPrefix, deploy.Application, deploy.Namespace)
 		secretPaths = append(secretPaths, naiserator.SecretPath{
 			KvPath:    defPath,
 			MountPath: "/var/run/secrets/nais.io/vault",
 		})
 	}
 
-	return naiserator.Application{
+	// TODO: REDIS_HOST with redis:true
+
+	defaults := naisd.DefaultManifest(deploy.Application).Healthcheck
+	liveness := probeConvert("liveness", manifest, manifest.Healthcheck.Liveness, defaults.Liveness, &rep)
+	readiness := probeConvert("readiness", manifest, manifest.Healthcheck.Readiness, defaults.Readiness, &rep)
+	prometheus := prometheusConvert(manifest, &rep)
+	vault := vaultConvert(manifest, secretPaths, &rep)
+	gcp := gcpBuckets(overrides.Buckets, target, &rep)
+	logformat, logtransform, secureLogs := logConvert(manifest, overrides.SecureLogs, &rep)
+
+	application := naiserator.Application{
 		TypeMeta: naiserator.TypeMeta{
 			Kind:       "Application",
 			APIVersion: "nais.io/v1alpha1",
 		},
 		ObjectMeta: naiserator.ObjectMeta{
 			Name: deploy.Application,
-			Labels: map[string]string{
-				"team": manifest.Team,
-			},
-			Namespace: deploy.Namespace,
+			Labels: config.MergeMap(overrides.Labels, map[string]string{
+				"team": team,
+			}),
+			Annotations: config.MergeMap(nil, overrides.Annotations),
+			Namespace:   deploy.Namespace,
 		},
 		Spec: naiserator.ApplicationSpec{
-			Image: manifest.Image,
-			Port:  manifest.Port,
+			AccessPolicy: accessPolicy,
+			FilesFrom:    converted.Files,
+			GCP:          gcp,
+			Image:        manifest.Image,
+			Port:         manifest.Port,
 			Strategy: &naiserator.Strategy{
 				Type: manifest.DeploymentStrategy,
 			},
-			Readiness:       probeConvert(manifest, manifest.Healthcheck.Readiness),
-			Liveness:        probeConvert(manifest, manifest.Healthcheck.Liveness),
+			Readiness:       readiness,
+			Liveness:        liveness,
 			PreStopHookPath: manifest.PreStopHookPath,
-			Prometheus:      prometheusConvert(manifest.Prometheus),
+			Prometheus:      prometheus,
 			Replicas:        replicaConvert(manifest.Replicas),
 			Ingresses:       ingresses,
 			Resources: naiserator.ResourceRequirements{
@@ -197,16 +272,16 @@ func Convert(manifest naisd.NaisManifest, deploy naisd.Deploy, resources []fasit
 
 			// TODO: create a configmap instead of environment variables?
 			// Maybe even configmap per system?
-			Env: fasitEnv(resources),
+			Env: converted.Env,
 
 			LeaderElection: manifest.LeaderElection,
-			Logformat:      manifest.Logformat,
-			Logtransform:   manifest.Logtransform,
-			Vault: naiserator.Vault{
-				Enabled: manifest.Secrets || len(secretPaths) > 0,
-				Mounts:  secretPaths,
-			},
-			WebProxy: manifest.Webproxy,
+			Logformat:      logformat,
+			Logtransform:   logtransform,
+			SecureLogs:     secureLogs,
+			Vault:          vault,
+			WebProxy:       webproxy,
 		},
 	}
+
+	return application, rep, nil
 }
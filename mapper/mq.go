@@ -0,0 +1,69 @@
+package mapper
+
+import (
+	"fmt"
+	"github.com/nais/migrator/fasit"
+	"github.com/nais/migrator/models/naiserator"
+	"regexp"
+	"strings"
+)
+
+const (
+	// MQ object names, such as channels, can not be longer than this.
+	mqChannelMaxLength = 20
+	mqQueueName        = "queueName"
+)
+
+var (
+	mqInvalidCharacters = regexp.MustCompile(`[^A-Z0-9._/%]`)
+	environmentToken    = regexp.MustCompile(`^[PQTU][0-9]*$`)
+)
+
+// mqChannel returns the channel name naisd computed for applications using a queue manager.
+func mqChannel(environment, application string) string {
+	channel := strings.ToUpper(fmt.Sprintf("%s_%s", environment, application))
+	return mqInvalidCharacters.ReplaceAllString(channel, "_")
+}
+
+// queueManagerHandler converts QueueManager resources. In addition to the queue manager properties,
+// the channel name that naisd used to compute is added, and the queue manager host is given outbound access.
+func queueManagerHandler(ctx ResourceContext, resource fasit.NaisResource) ResourceResult {
+	result := PropertiesHandler(ctx, resource)
+	result.External = externalHosts(ctx, resource)
+
+	channel := mqChannel(ctx.Deploy.FasitEnvironment, ctx.Deploy.Application)
+	result.Env = append(result.Env, naiserator.EnvVar{
+		Name:  resource.ToEnvironmentVariable("channel"),
+		Value: channel,
+	})
+
+	if len(channel) > mqChannelMaxLength {
+		result.Report.Warnf(source(resource), "Channel name '%s' is longer than %d characters; check the channel name used by the queue manager",
+			channel, mqChannelMaxLength)
+	}
+
+	if len(result.External) == 0 {
+		result.Report.Warnf(source(resource), "Queue manager has no host name; outbound access must be added by hand")
+	}
+
+	return result
+}
+
+// queueHandler converts Queue resources. Queue names often contain the environment they belong to, such as
+// QA.Q1_APP.REQUEST, so a name containing another environment is reported. This is a guess based on the name only.
+func queueHandler(ctx ResourceContext, resource fasit.NaisResource) ResourceResult {
+	result := PropertiesHandler(ctx, resource)
+
+	name := resource.Properties[mqQueueName]
+	environment := strings.ToUpper(ctx.Deploy.FasitEnvironment)
+
+	for _, token := range strings.FieldsFunc(strings.ToUpper(name), func(r rune) bool { return r == '.' || r == '_' }) {
+		if environmentToken.MatchString(token) && token != environment {
+			result.Report.Warnf(source(resource), "Queue name '%s' looks like it belongs to environment '%s', but environment '%s' is being migrated; "+
+				"this is guessed from the name, so check the queue in Fasit", name, token, environment)
+			break
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,127 @@
+package mapper
+
+import (
+	"github.com/nais/migrator/fasit"
+	"github.com/nais/migrator/models/naisd"
+	"github.com/nais/migrator/models/naiserator"
+	"github.com/nais/migrator/report"
+	"testing"
+)
+
+func TestMqChannel(t *testing.T) {
+	tests := []struct {
+		environment string
+		application string
+		channel     string
+	}{
+		{environment: "q1", application: "app", channel: "Q1_APP"},
+		{environment: "p", application: "my-app", channel: "P_MY_APP"},
+		{environment: "t6", application: "app.name", channel: "T6_APP.NAME"},
+		{environment: "", application: "app", channel: "_APP"},
+	}
+
+	for _, test := range tests {
+		if channel := mqChannel(test.environment, test.application); channel != test.channel {
+			t.Errorf("channel for %s/%s is %s, want %s", test.environment, test.application, channel, test.channel)
+		}
+	}
+}
+
+func TestQueueManagerHandler(t *testing.T) {
+	resource := fasit.NaisResource{
+		Name:         "mqGateway01",
+		ResourceType: "QueueManager",
+		Properties:   map[string]string{"name": "MQLS01", "hostname": "mq.preprod.local", "port": "1413"},
+	}
+
+	tests := []struct {
+		name        string
+		application string
+		channel     string
+		long        bool
+	}{
+		{name: "short channel", application: "app", channel: "Q1_APP"},
+		{name: "long channel", application: "application-with-long-name", channel: "Q1_APPLICATION_WITH_LONG_NAME", long: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := ResourceContext{Deploy: naisd.Deploy{Application: test.application, Zone: naisd.ZONE_FSS, FasitEnvironment: "q1"}}
+			result := queueManagerHandler(ctx, resource)
+
+			if !hasEnv(result.Env, "MQGATEWAY01_CHANNEL", test.channel) {
+				t.Errorf("expected MQGATEWAY01_CHANNEL=%s, got %v", test.channel, result.Env)
+			}
+			if len(result.External) != 1 || result.External[0].Host != "mq.preprod.local" {
+				t.Errorf("expected outbound access to the queue manager host, got %v", result.External)
+			}
+			if long := reported(result.Report, report.Warning, "is longer than 20 characters"); long != test.long {
+				t.Errorf("long channel reported %v, want %v: %v", long, test.long, result.Report.Findings)
+			}
+		})
+	}
+}
+
+func TestQueueHandler(t *testing.T) {
+	tests := []struct {
+		name        string
+		environment string
+		queue       string
+		// warning is part of the expected warning, if any.
+		warning string
+	}{
+		{name: "queue in the migrated environment", environment: "q1", queue: "QA.Q1_APP.REQUEST"},
+		{name: "lower case environment", environment: "q1", queue: "qa.q1_app.request"},
+		{name: "no environment token", environment: "q1", queue: "QA.APP.REQUEST"},
+		{name: "tokens that are not environments", environment: "p", queue: "QA.PX.QUEUE1"},
+		{
+			name:        "queue in another environment",
+			environment: "q1",
+			queue:       "QA.Q2_APP.REQUEST",
+			warning:     "Queue name 'QA.Q2_APP.REQUEST' looks like it belongs to environment 'Q2', but environment 'Q1' is being migrated",
+		},
+		{
+			name:        "production queue in a test environment",
+			environment: "t6",
+			queue:       "P_APP.REQUEST",
+			warning:     "looks like it belongs to environment 'P', but environment 'T6' is being migrated",
+		},
+		{
+			name:        "environment token not known to Fasit",
+			environment: "q1",
+			queue:       "QA.U99_APP.REQUEST",
+			warning:     "looks like it belongs to environment 'U99', but environment 'Q1' is being migrated",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := ResourceContext{Deploy: naisd.Deploy{Application: "app", Zone: naisd.ZONE_FSS, FasitEnvironment: test.environment}}
+			resource := fasit.NaisResource{Name: "app_request", ResourceType: "Queue", Properties: map[string]string{mqQueueName: test.queue}}
+			result := queueHandler(ctx, resource)
+
+			if !hasEnv(result.Env, "APP_REQUEST_QUEUENAME", test.queue) {
+				t.Errorf("expected APP_REQUEST_QUEUENAME=%s, got %v", test.queue, result.Env)
+			}
+			if len(test.warning) == 0 {
+				if result.Report.Count(report.Warning) > 0 {
+					t.Errorf("unexpected warnings: %v", result.Report.Findings)
+				}
+				return
+			}
+			if !reported(result.Report, report.Warning, test.warning) {
+				t.Errorf("expected a warning containing '%s', got %v", test.warning, result.Report.Findings)
+			}
+		})
+	}
+}
+
+// hasEnv returns true if the variable is among the converted variables.
+func hasEnv(vars []naiserator.EnvVar, name, value string) bool {
+	for _, v := range vars {
+		if v == env(name, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,310 @@
+// Package kubernetes contains the subset of the Kubernetes data model needed to render applications
+// into plain Kubernetes objects.
+package kubernetes
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// IntOrString holds a value that is either a number or a name, such as a port.
+type IntOrString string
+
+// FromInt returns a numeric IntOrString.
+func FromInt(n int) IntOrString {
+	return IntOrString(strconv.Itoa(n))
+}
+
+// MarshalYAML writes numbers as numbers.
+func (v IntOrString) MarshalYAML() (interface{}, error) {
+	if n, err := strconv.Atoi(string(v)); err == nil {
+		return n, nil
+	}
+	return string(v), nil
+}
+
+// UnmarshalYAML reads both numbers and names. Other values, such as lists and maps, are errors.
+func (v *IntOrString) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var value interface{}
+	err := unmarshal(&value)
+	if err != nil {
+		return err
+	}
+
+	switch x := value.(type) {
+	case nil:
+		*v = ""
+	case int:
+		*v = FromInt(x)
+	case string:
+		*v = IntOrString(x)
+	default:
+		return fmt.Errorf("'%v' is neither a number nor a name", value)
+	}
+	return nil
+}
+
+type TypeMeta struct {
+	APIVersion string `yaml:"apiVersion"`
+	Kind       string `yaml:"kind"`
+}
+
+type ObjectMeta struct {
+	Name        string            `yaml:"name,omitempty"`
+	Namespace   string            `yaml:"namespace,omitempty"`
+	Labels      map[string]string `yaml:"labels,omitempty"`
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+}
+
+type LabelSelector struct {
+	MatchLabels map[string]string `yaml:"matchLabels"`
+}
+
+type Deployment struct {
+	TypeMeta   `yaml:",inline"`
+	ObjectMeta `yaml:"metadata"`
+
+	Spec DeploymentSpec `yaml:"spec"`
+}
+
+type DeploymentSpec struct {
+	Replicas                int                `yaml:"replicas"`
+	Selector                LabelSelector      `yaml:"selector"`
+	Strategy                DeploymentStrategy `yaml:"strategy"`
+	Template                PodTemplateSpec    `yaml:"template"`
+	ProgressDeadlineSeconds int                `yaml:"progressDeadlineSeconds,omitempty"`
+	RevisionHistoryLimit    int                `yaml:"revisionHistoryLimit,omitempty"`
+}
+
+type DeploymentStrategy struct {
+	Type          string                   `yaml:"type"`
+	RollingUpdate *RollingUpdateDeployment `yaml:"rollingUpdate,omitempty"`
+}
+
+type RollingUpdateDeployment struct {
+	MaxUnavailable IntOrString `yaml:"maxUnavailable"`
+	MaxSurge       IntOrString `yaml:"maxSurge"`
+}
+
+type PodTemplateSpec struct {
+	ObjectMeta `yaml:"metadata"`
+
+	Spec PodSpec `yaml:"spec"`
+}
+
+type PodSpec struct {
+	ServiceAccountName            string      `yaml:"serviceAccountName,omitempty"`
+	InitContainers                []Container `yaml:"initContainers,omitempty"`
+	Containers                    []Container `yaml:"containers"`
+	Volumes                       []Volume    `yaml:"volumes,omitempty"`
+	RestartPolicy                 string      `yaml:"restartPolicy,omitempty"`
+	DNSPolicy                     string      `yaml:"dnsPolicy,omitempty"`
+	TerminationGracePeriodSeconds int         `yaml:"terminationGracePeriodSeconds,omitempty"`
+}
+
+type Container struct {
+	Name            string               `yaml:"name"`
+	Image           string               `yaml:"image"`
+	ImagePullPolicy string               `yaml:"imagePullPolicy,omitempty"`
+	Args            []string             `yaml:"args,omitempty"`
+	Ports           []ContainerPort      `yaml:"ports,omitempty"`
+	Env             []EnvVar             `yaml:"env,omitempty"`
+	EnvFrom         []EnvFromSource      `yaml:"envFrom,omitempty"`
+	Resources       ResourceRequirements `yaml:"resources,omitempty"`
+	VolumeMounts    []VolumeMount        `yaml:"volumeMounts,omitempty"`
+	LivenessProbe   *Probe               `yaml:"livenessProbe,omitempty"`
+	ReadinessProbe  *Probe               `yaml:"readinessProbe,omitempty"`
+	Lifecycle       *Lifecycle           `yaml:"lifecycle,omitempty"`
+}
+
+type ContainerPort struct {
+	Name          string `yaml:"name,omitempty"`
+	ContainerPort int    `yaml:"containerPort"`
+	Protocol      string `yaml:"protocol,omitempty"`
+}
+
+type EnvVar struct {
+	Name      string        `yaml:"name"`
+	Value     string        `yaml:"value,omitempty"`
+	ValueFrom *EnvVarSource `yaml:"valueFrom,omitempty"`
+}
+
+type EnvVarSource struct {
+	FieldRef         *ObjectFieldSelector   `yaml:"fieldRef,omitempty"`
+	ResourceFieldRef *ResourceFieldSelector `yaml:"resourceFieldRef,omitempty"`
+	ConfigMapKeyRef  *KeySelector           `yaml:"configMapKeyRef,omitempty"`
+	SecretKeyRef     *KeySelector           `yaml:"secretKeyRef,omitempty"`
+}
+
+type ResourceFieldSelector struct {
+	ContainerName string `yaml:"containerName,omitempty"`
+	Resource      string `yaml:"resource"`
+}
+
+// KeySelector selects a key of a ConfigMap or a Secret.
+type KeySelector struct {
+	Name     string `yaml:"name"`
+	Key      string `yaml:"key"`
+	Optional bool   `yaml:"optional,omitempty"`
+}
+
+type ObjectFieldSelector struct {
+	FieldPath string `yaml:"fieldPath"`
+}
+
+type EnvFromSource struct {
+	ConfigMapRef *LocalObjectReference `yaml:"configMapRef,omitempty"`
+	SecretRef    *LocalObjectReference `yaml:"secretRef,omitempty"`
+}
+
+type LocalObjectReference struct {
+	Name string `yaml:"name"`
+}
+
+type ResourceRequirements struct {
+	Limits   map[string]string `yaml:"limits,omitempty"`
+	Requests map[string]string `yaml:"requests,omitempty"`
+}
+
+type VolumeMount struct {
+	Name      string `yaml:"name"`
+	MountPath string `yaml:"mountPath"`
+	SubPath   string `yaml:"subPath,omitempty"`
+	ReadOnly  bool   `yaml:"readOnly,omitempty"`
+}
+
+type Volume struct {
+	Name      string                 `yaml:"name"`
+	Secret    *SecretVolumeSource    `yaml:"secret,omitempty"`
+	ConfigMap *ConfigMapVolumeSource `yaml:"configMap,omitempty"`
+	EmptyDir  *EmptyDirVolumeSource  `yaml:"emptyDir,omitempty"`
+}
+
+type SecretVolumeSource struct {
+	SecretName string `yaml:"secretName"`
+}
+
+type ConfigMapVolumeSource struct {
+	Name string `yaml:"name"`
+}
+
+type EmptyDirVolumeSource struct {
+	Medium string `yaml:"medium,omitempty"`
+}
+
+type Probe struct {
+	HTTPGet             *HTTPGetAction `yaml:"httpGet,omitempty"`
+	InitialDelaySeconds int            `yaml:"initialDelaySeconds,omitempty"`
+	PeriodSeconds       int            `yaml:"periodSeconds,omitempty"`
+	FailureThreshold    int            `yaml:"failureThreshold,omitempty"`
+	TimeoutSeconds      int            `yaml:"timeoutSeconds,omitempty"`
+}
+
+type HTTPGetAction struct {
+	Path string      `yaml:"path"`
+	Port IntOrString `yaml:"port"`
+}
+
+type ExecAction struct {
+	Command []string `yaml:"command"`
+}
+
+type Handler struct {
+	Exec    *ExecAction    `yaml:"exec,omitempty"`
+	HTTPGet *HTTPGetAction `yaml:"httpGet,omitempty"`
+}
+
+type Lifecycle struct {
+	PreStop *Handler `yaml:"preStop,omitempty"`
+}
+
+type Service struct {
+	TypeMeta   `yaml:",inline"`
+	ObjectMeta `yaml:"metadata"`
+
+	Spec ServiceSpec `yaml:"spec"`
+}
+
+type ServiceSpec struct {
+	Type     string            `yaml:"type"`
+	Selector map[string]string `yaml:"selector"`
+	Ports    []ServicePort     `yaml:"ports"`
+}
+
+type ServicePort struct {
+	Name       string      `yaml:"name"`
+	Protocol   string      `yaml:"protocol"`
+	Port       int         `yaml:"port"`
+	TargetPort IntOrString `yaml:"targetPort"`
+}
+
+type HorizontalPodAutoscaler struct {
+	TypeMeta   `yaml:",inline"`
+	ObjectMeta `yaml:"metadata"`
+
+	Spec HorizontalPodAutoscalerSpec `yaml:"spec"`
+}
+
+type HorizontalPodAutoscalerSpec struct {
+	ScaleTargetRef                 CrossVersionObjectReference `yaml:"scaleTargetRef"`
+	MinReplicas                    int                         `yaml:"minReplicas"`
+	MaxReplicas                    int                         `yaml:"maxReplicas"`
+	TargetCPUUtilizationPercentage int                         `yaml:"targetCPUUtilizationPercentage"`
+}
+
+type CrossVersionObjectReference struct {
+	APIVersion string `yaml:"apiVersion"`
+	Kind       string `yaml:"kind"`
+	Name       string `yaml:"name"`
+}
+
+type Ingress struct {
+	TypeMeta   `yaml:",inline"`
+	ObjectMeta `yaml:"metadata"`
+
+	Spec IngressSpec `yaml:"spec"`
+}
+
+type IngressSpec struct {
+	Rules []IngressRule `yaml:"rules"`
+}
+
+type IngressRule struct {
+	Host string               `yaml:"host"`
+	HTTP HTTPIngressRuleValue `yaml:"http"`
+}
+
+type HTTPIngressRuleValue struct {
+	Paths []HTTPIngressPath `yaml:"paths"`
+}
+
+type HTTPIngressPath struct {
+	Path    string         `yaml:"path"`
+	Backend IngressBackend `yaml:"backend"`
+}
+
+type IngressBackend struct {
+	ServiceName string      `yaml:"serviceName"`
+	ServicePort IntOrString `yaml:"servicePort"`
+}
+
+type ConfigMap struct {
+	TypeMeta   `yaml:",inline"`
+	ObjectMeta `yaml:"metadata"`
+
+	Data map[string]string `yaml:"data,omitempty"`
+}
+
+type Secret struct {
+	TypeMeta   `yaml:",inline"`
+	ObjectMeta `yaml:"metadata"`
+
+	Type       string            `yaml:"type,omitempty"`
+	Data       map[string]string `yaml:"data,omitempty"`
+	StringData map[string]string `yaml:"stringData,omitempty"`
+}
+
+type ServiceAccount struct {
+	TypeMeta   `yaml:",inline"`
+	ObjectMeta `yaml:"metadata"`
+}
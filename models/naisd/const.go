@@ -5,4 +5,6 @@ const (
 	ZONE_IAPP     = "iapp"
 	ZONE_FSS      = "fss"
 	ENVIRONMENT_P = "p"
+
+	NAMESPACE_DEFAULT = "default"
 )
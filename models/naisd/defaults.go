@@ -0,0 +1,60 @@
+package naisd
+
+const DefaultImageRegistry = "docker.adeo.no:5000/"
+
+// DefaultManifest returns the manifest naisd merged the team's manifest onto.
+// Decoding a manifest into this value yields the configuration naisd actually deployed.
+func DefaultManifest(application string) NaisManifest {
+	return NaisManifest{
+		Image: DefaultImageRegistry + application,
+		Port:  8080,
+		Healthcheck: Healthcheck{
+			Liveness: Probe{
+				Path:             "isalive",
+				InitialDelay:     20,
+				PeriodSeconds:    10,
+				FailureThreshold: 3,
+				Timeout:          1,
+			},
+			Readiness: Probe{
+				Path:             "isready",
+				InitialDelay:     20,
+				PeriodSeconds:    10,
+				FailureThreshold: 3,
+				Timeout:          1,
+			},
+		},
+		Prometheus: PrometheusConfig{
+			Enabled: false,
+			Port:    "http",
+			Path:    "/metrics",
+		},
+		Replicas: Replicas{
+			Min:                    2,
+			Max:                    4,
+			CpuThresholdPercentage: 50,
+		},
+		Resources: ResourceRequirements{
+			Limits: ResourceList{
+				Cpu:    "500m",
+				Memory: "512Mi",
+			},
+			Requests: ResourceList{
+				Cpu:    "200m",
+				Memory: "256Mi",
+			},
+		},
+		Redis: Redis{
+			Image: "redis:5-alpine",
+			Limits: ResourceList{
+				Cpu:    "100m",
+				Memory: "128Mi",
+			},
+			Requests: ResourceList{
+				Cpu:    "100m",
+				Memory: "128Mi",
+			},
+		},
+		DeploymentStrategy: "RollingUpdate",
+	}
+}
@@ -0,0 +1,136 @@
+package patch
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	"strings"
+)
+
+// operation is a single JSON Patch operation, kept with its position for error messages.
+type operation struct {
+	Op   string
+	Path string
+	jsonpatch.Operation
+}
+
+// parseOperations checks the operations of a JSON Patch, and decodes them for the JSON Patch implementation.
+func parseOperations(document []interface{}) ([]operation, error) {
+	operations := make([]operation, 0, len(document))
+
+	for i, item := range document {
+		fields, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("operation %d is not an object", i+1)
+		}
+
+		op := operation{}
+		op.Op, _ = fields["op"].(string)
+		op.Path, ok = fields["path"].(string)
+		if !ok {
+			return nil, fmt.Errorf("operation %d is missing 'path'", i+1)
+		}
+
+		switch op.Op {
+		case "add", "replace", "test":
+			if _, ok := fields["value"]; !ok {
+				return nil, fmt.Errorf("operation %d (%s) is missing 'value'", i+1, op.Op)
+			}
+		case "move", "copy":
+			from, ok := fields["from"].(string)
+			if !ok {
+				return nil, fmt.Errorf("operation %d (%s) is missing 'from'", i+1, op.Op)
+			}
+			// A value can not be moved into one of its own children (RFC 6902, section 4.4).
+			if op.Op == "move" && strings.HasPrefix(op.Path, from+"/") {
+				return nil, fmt.Errorf("operation %d (move) can not move '%s' into its own child '%s'", i+1, from, op.Path)
+			}
+		case "remove":
+		default:
+			return nil, fmt.Errorf("operation %d has unsupported op '%s'", i+1, op.Op)
+		}
+
+		data, err := json.Marshal(fields)
+		if err != nil {
+			return nil, fmt.Errorf("operation %d: %s", i+1, err)
+		}
+		err = json.Unmarshal(data, &op.Operation)
+		if err != nil {
+			return nil, fmt.Errorf("operation %d: %s", i+1, err)
+		}
+
+		operations = append(operations, op)
+	}
+
+	return operations, nil
+}
+
+// apply applies the operation to a document, following RFC 6902. Adding to a location whose parent does not exist
+// is an error; the parent must be added first.
+func (op operation) apply(document interface{}) (interface{}, error) {
+	data, err := json.Marshal(document)
+	if err != nil {
+		return document, err
+	}
+
+	data, err = jsonpatch.Patch{op.Operation}.Apply(data)
+	if err != nil {
+		return document, err
+	}
+
+	return fromJSON(data)
+}
+
+// fromJSON decodes a JSON document, keeping whole numbers as integers so that they are encoded as such in YAML.
+func fromJSON(data []byte) (interface{}, error) {
+	var document interface{}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	err := decoder.Decode(&document)
+	if err != nil {
+		return nil, err
+	}
+
+	return numbers(document), nil
+}
+
+func numbers(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, val := range v {
+			v[key] = numbers(val)
+		}
+	case []interface{}:
+		for i, val := range v {
+			v[i] = numbers(val)
+		}
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	}
+	return value
+}
+
+func deepCopy(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, val := range v {
+			m[key] = deepCopy(val)
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, len(v))
+		for i, val := range v {
+			l[i] = deepCopy(val)
+		}
+		return l
+	default:
+		return value
+	}
+}
@@ -0,0 +1,66 @@
+package patch
+
+// mergeKey is the field used to match list elements when merging lists of objects.
+const mergeKey = "name"
+
+// mergePatch applies a merge patch to target. Objects are merged recursively and null values
+// remove fields. Lists of named objects are merged by name, all other lists are replaced.
+func mergePatch(target interface{}, patch interface{}) interface{} {
+	switch p := patch.(type) {
+	case map[string]interface{}:
+		t, ok := target.(map[string]interface{})
+		if !ok {
+			t = make(map[string]interface{}, len(p))
+		}
+		for key, value := range p {
+			if value == nil {
+				delete(t, key)
+				continue
+			}
+			t[key] = mergePatch(t[key], value)
+		}
+		return t
+	case []interface{}:
+		t, ok := target.([]interface{})
+		if ok && named(t) && named(p) {
+			return mergeNamed(t, p)
+		}
+		return deepCopy(p)
+	default:
+		return patch
+	}
+}
+
+func mergeNamed(target []interface{}, patch []interface{}) []interface{} {
+	for _, item := range patch {
+		element := item.(map[string]interface{})
+		found := false
+		for i, existing := range target {
+			if existing.(map[string]interface{})[mergeKey] == element[mergeKey] {
+				target[i] = mergePatch(existing, element)
+				found = true
+				break
+			}
+		}
+		if !found {
+			target = append(target, deepCopy(element))
+		}
+	}
+	return target
+}
+
+func named(list []interface{}) bool {
+	if len(list) == 0 {
+		return false
+	}
+	for _, item := range list {
+		element, ok := item.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		if _, ok := element[mergeKey]; !ok {
+			return false
+		}
+	}
+	return true
+}
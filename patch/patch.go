@@ -0,0 +1,135 @@
+// Package patch applies user supplied patches to a converted Naiserator application.
+//
+// Two patch formats are supported. A YAML or JSON document containing a list is treated as
+// a JSON Patch (RFC 6902), while a document containing an object is treated as a merge patch.
+// Merge patches follow RFC 7386, with one strategic addition: lists where every element is an
+// object with a "name" field, such as spec.env, are merged element by element on that name.
+//
+// After all patches are applied, the result is decoded strictly back into the application model,
+// so that patches referring to fields that do not exist, or using the wrong types, are rejected.
+package patch
+
+import (
+	"fmt"
+	"github.com/nais/migrator/models/naiserator"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+)
+
+// Patch is a single parsed patch document.
+type Patch struct {
+	// Source is where the patch was read from, used in error messages.
+	Source     string
+	operations []operation
+	merge      map[string]interface{}
+}
+
+// Load reads a patch from a file.
+func Load(path string) (Patch, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Patch{}, fmt.Errorf("read patch: %s", err)
+	}
+	return Parse(path, data)
+}
+
+// Parse decodes a patch document, detecting its format from the document structure.
+func Parse(source string, data []byte) (Patch, error) {
+	var document interface{}
+
+	err := yaml.Unmarshal(data, &document)
+	if err != nil {
+		return Patch{}, fmt.Errorf("patch %s: %s", source, err)
+	}
+
+	patch := Patch{Source: source}
+
+	switch doc := normalize(document).(type) {
+	case []interface{}:
+		patch.operations, err = parseOperations(doc)
+		if err != nil {
+			return Patch{}, fmt.Errorf("patch %s: %s", source, err)
+		}
+	case map[string]interface{}:
+		patch.merge = doc
+	default:
+		return Patch{}, fmt.Errorf("patch %s: document must be a list of operations or an object", source)
+	}
+
+	return patch, nil
+}
+
+// Apply applies patches in order and returns the resulting application.
+func Apply(application naiserator.Application, patches []Patch) (naiserator.Application, error) {
+	if len(patches) == 0 {
+		return application, nil
+	}
+
+	data, err := yaml.Marshal(application)
+	if err != nil {
+		return application, fmt.Errorf("encode application: %s", err)
+	}
+
+	var document interface{}
+	err = yaml.Unmarshal(data, &document)
+	if err != nil {
+		return application, fmt.Errorf("decode application: %s", err)
+	}
+	document = normalize(document)
+
+	for _, patch := range patches {
+		if patch.merge != nil {
+			document = mergePatch(document, patch.merge)
+			continue
+		}
+		for i, op := range patch.operations {
+			document, err = op.apply(document)
+			if err != nil {
+				return application, fmt.Errorf("patch %s: operation %d (%s %s): %s", patch.Source, i+1, op.Op, op.Path, err)
+			}
+		}
+	}
+
+	data, err = yaml.Marshal(document)
+	if err != nil {
+		return application, fmt.Errorf("encode patched application: %s", err)
+	}
+
+	var patched naiserator.Application
+	err = yaml.UnmarshalStrict(data, &patched)
+	if err != nil {
+		return application, fmt.Errorf("patched application does not match the Application model: %s", err)
+	}
+
+	err = validate(patched)
+	if err != nil {
+		return application, fmt.Errorf("patched application is invalid: %s", err)
+	}
+
+	return patched, nil
+}
+
+// normalize converts the map[interface{}]interface{} values produced by the YAML decoder
+// into map[string]interface{}, so that patches can be applied uniformly.
+func normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, val := range v {
+			m[fmt.Sprintf("%v", key)] = normalize(val)
+		}
+		return m
+	case map[string]interface{}:
+		for key, val := range v {
+			v[key] = normalize(val)
+		}
+		return v
+	case []interface{}:
+		for i, val := range v {
+			v[i] = normalize(val)
+		}
+		return v
+	default:
+		return value
+	}
+}
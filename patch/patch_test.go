@@ -0,0 +1,232 @@
+package patch
+
+import (
+	"github.com/nais/migrator/models/naiserator"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func application() naiserator.Application {
+	return naiserator.Application{
+		TypeMeta:   naiserator.TypeMeta{Kind: "Application", APIVersion: "nais.io/v1alpha1"},
+		ObjectMeta: naiserator.ObjectMeta{Name: "app", Namespace: "team"},
+		Spec: naiserator.ApplicationSpec{
+			Image:     "docker.adeo.no:5000/app:1",
+			Port:      8080,
+			Ingresses: []string{"https://app.nais.adeo.no", "https://app.intern.nav.no"},
+			Env: []naiserator.EnvVar{
+				{Name: "FOO", Value: "foo"},
+				{Name: "BAR", Value: "bar"},
+			},
+			Replicas: naiserator.Replicas{Min: 2, Max: 4},
+		},
+	}
+}
+
+func TestApplyJSONPatch(t *testing.T) {
+	tests := []struct {
+		name   string
+		patch  string
+		expect func(*naiserator.Application)
+		err    string
+	}{
+		{
+			name:   "add a field",
+			patch:  "- {op: add, path: /spec/webproxy, value: true}",
+			expect: func(app *naiserator.Application) { app.Spec.WebProxy = true },
+		},
+		{
+			name:   "add an object",
+			patch:  "- {op: add, path: /spec/secureLogs, value: {enabled: true}}",
+			expect: func(app *naiserator.Application) { app.Spec.SecureLogs.Enabled = true },
+		},
+		{
+			name:  "add to a missing parent",
+			patch: "- {op: add, path: /spec/secureLogs/enabled, value: true}",
+			err:   "missing path",
+		},
+		{
+			name:  "add to a list",
+			patch: "- {op: add, path: /spec/ingresses/1, value: 'https://app.adeo.no'}\n- {op: add, path: /spec/ingresses/-, value: 'https://app.nav.no'}",
+			expect: func(app *naiserator.Application) {
+				app.Spec.Ingresses = []string{"https://app.nais.adeo.no", "https://app.adeo.no", "https://app.intern.nav.no", "https://app.nav.no"}
+			},
+		},
+		{
+			name:  "add beyond the end of a list",
+			patch: "- {op: add, path: /spec/ingresses/3, value: 'https://app.adeo.no'}",
+			err:   "invalid index",
+		},
+		{
+			name:   "remove",
+			patch:  "- {op: remove, path: /spec/env/0}",
+			expect: func(app *naiserator.Application) { app.Spec.Env = app.Spec.Env[1:] },
+		},
+		{
+			name:  "remove a missing field",
+			patch: "- {op: remove, path: /spec/webproxy}",
+			err:   "nonexistent key",
+		},
+		{
+			name:   "replace",
+			patch:  "- {op: replace, path: /spec/replicas/max, value: 6}",
+			expect: func(app *naiserator.Application) { app.Spec.Replicas.Max = 6 },
+		},
+		{
+			name:  "replace a missing field",
+			patch: "- {op: replace, path: /spec/webproxy, value: true}",
+			err:   "missing key",
+		},
+		{
+			name:   "move",
+			patch:  "- {op: move, from: /spec/env/1, path: /spec/env/0}",
+			expect: func(app *naiserator.Application) { app.Spec.Env[0], app.Spec.Env[1] = app.Spec.Env[1], app.Spec.Env[0] },
+		},
+		{
+			name:   "copy",
+			patch:  "- {op: copy, from: /spec/replicas/min, path: /spec/replicas/max}",
+			expect: func(app *naiserator.Application) { app.Spec.Replicas.Max = 2 },
+		},
+		{
+			name:   "test",
+			patch:  "- {op: test, path: /spec/port, value: 8080}\n- {op: replace, path: /spec/port, value: 8443}",
+			expect: func(app *naiserator.Application) { app.Spec.Port = 8443 },
+		},
+		{
+			name:  "failing test",
+			patch: "- {op: test, path: /spec/port, value: 80}",
+			err:   "testing value /spec/port failed",
+		},
+		{
+			name:  "unknown field",
+			patch: "- {op: add, path: /spec/unknown, value: 1}",
+			err:   "does not match the Application model",
+		},
+		{
+			name:  "wrong type",
+			patch: "- {op: replace, path: /spec/port, value: http}",
+			err:   "does not match the Application model",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p, err := Parse(test.name, []byte(test.patch))
+			if err != nil {
+				t.Fatalf("parse: %s", err)
+			}
+
+			patched, err := Apply(application(), []Patch{p})
+			if len(test.err) > 0 {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("expected an error containing '%s', got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("apply: %s", err)
+			}
+
+			expected := application()
+			test.expect(&expected)
+			if !reflect.DeepEqual(patched, expected) {
+				t.Fatalf("patched application differs:\n got: %+v\nwant: %+v", patched.Spec, expected.Spec)
+			}
+		})
+	}
+}
+
+func TestParseJSONPatch(t *testing.T) {
+	tests := map[string]string{
+		"- {op: add, value: 1}":                        "missing 'path'",
+		"- {op: add, path: /spec/port}":                "missing 'value'",
+		"- {op: copy, path: /spec/port}":               "missing 'from'",
+		"- {op: merge, path: /spec/port}":              "unsupported op 'merge'",
+		"- {op: move, from: /spec, path: /spec/vault}": "into its own child",
+		"- just a string":                              "not an object",
+		"just a string":                                "must be a list of operations or an object",
+	}
+
+	for patch, expected := range tests {
+		_, err := Parse("test", []byte(patch))
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("%s: expected an error containing '%s', got %v", patch, expected, err)
+		}
+	}
+}
+
+func TestApplyMergePatch(t *testing.T) {
+	tests := []struct {
+		name   string
+		patch  string
+		expect func(*naiserator.Application)
+	}{
+		{
+			name:   "fields are merged",
+			patch:  "spec: {skipCaBundle: true, replicas: {max: 8}}",
+			expect: func(app *naiserator.Application) { app.Spec.SkipCaBundle = true; app.Spec.Replicas.Max = 8 },
+		},
+		{
+			name:   "null removes a field",
+			patch:  "spec: {replicas: {min: null}}",
+			expect: func(app *naiserator.Application) { app.Spec.Replicas.Min = 0 },
+		},
+		{
+			name:  "named lists are merged by name",
+			patch: "spec: {env: [{name: BAR, value: changed}, {name: BAZ, value: baz}]}",
+			expect: func(app *naiserator.Application) {
+				app.Spec.Env = []naiserator.EnvVar{{Name: "FOO", Value: "foo"}, {Name: "BAR", Value: "changed"}, {Name: "BAZ", Value: "baz"}}
+			},
+		},
+		{
+			name:   "other lists are replaced",
+			patch:  "spec: {ingresses: ['https://app.nav.no']}",
+			expect: func(app *naiserator.Application) { app.Spec.Ingresses = []string{"https://app.nav.no"} },
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p, err := Parse(test.name, []byte(test.patch))
+			if err != nil {
+				t.Fatalf("parse: %s", err)
+			}
+
+			patched, err := Apply(application(), []Patch{p})
+			if err != nil {
+				t.Fatalf("apply: %s", err)
+			}
+
+			expected := application()
+			test.expect(&expected)
+			if !reflect.DeepEqual(patched, expected) {
+				t.Fatalf("patched application differs:\n got: %+v\nwant: %+v", patched.Spec, expected.Spec)
+			}
+		})
+	}
+}
+
+func TestApplyValidation(t *testing.T) {
+	tests := map[string]string{
+		"spec: {image: null}":                 "spec.image must be set",
+		"spec: {logformat: xml}":              "spec.logformat 'xml'",
+		"spec: {logtransform: json}":          "spec.logtransform 'json'",
+		"spec: {strategy: {type: BlueGreen}}": "spec.strategy.type 'BlueGreen'",
+		"spec: {vault: {paths: [{kvPath: /a, mountPath: /b, format: ini}]}}":            "format 'ini'",
+		"spec: {env: [{name: POD, valueFrom: {fieldRef: {fieldPath: spec.hostname}}}]}": "fieldPath 'spec.hostname'",
+		"spec: {resources: {limits: {cpu: 1.5}}}":                                       "spec.resources.limits.cpu '1.5'",
+		"spec: {resources: {requests: {memory: 512MB}}}":                                "spec.resources.requests.memory '512MB'",
+	}
+
+	for patch, expected := range tests {
+		p, err := Parse("test", []byte(patch))
+		if err != nil {
+			t.Fatalf("%s: parse: %s", patch, err)
+		}
+		_, err = Apply(application(), []Patch{p})
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("%s: expected an error containing '%s', got %v", patch, expected, err)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package patch
+
+import (
+	"fmt"
+	"github.com/nais/migrator/models/naiserator"
+	"regexp"
+)
+
+// Allowed values, mirroring the validation markers in the Application model. TestValidationMatchesModel fails
+// when the markers change, so that these are kept in step.
+var (
+	logformats    = []string{"", "accesslog", "accesslog_with_processing_time", "accesslog_with_referer_useragent", "capnslog", "logrus", "gokit", "redis", "glog", "simple", "influxdb", "log15"}
+	logtransforms = []string{"", "dns_loglevel", "http_loglevel"}
+	strategies    = []string{"Recreate", "RollingUpdate"}
+	formats       = []string{"", "flatten", "yaml", "env", "properties"}
+	fieldPaths    = []string{"", "metadata.name", "metadata.namespace", "metadata.labels", "metadata.annotations", "spec.nodeName", "spec.serviceAccountName", "status.hostIP", "status.podIP"}
+
+	cpuPattern    = regexp.MustCompile(`^\d+m?$`)
+	memoryPattern = regexp.MustCompile(`^\d+[KMG]i$`)
+)
+
+func validate(application naiserator.Application) error {
+	spec := application.Spec
+
+	if len(spec.Image) == 0 {
+		return fmt.Errorf("spec.image must be set")
+	}
+	if !oneOf(spec.Logformat, logformats) {
+		return fmt.Errorf("spec.logformat '%s' must be one of %v", spec.Logformat, logformats)
+	}
+	if !oneOf(spec.Logtransform, logtransforms) {
+		return fmt.Errorf("spec.logtransform '%s' must be one of %v", spec.Logtransform, logtransforms)
+	}
+	if spec.Strategy != nil && len(spec.Strategy.Type) > 0 && !oneOf(spec.Strategy.Type, strategies) {
+		return fmt.Errorf("spec.strategy.type '%s' must be one of %v", spec.Strategy.Type, strategies)
+	}
+	for _, mount := range spec.Vault.Mounts {
+		if !oneOf(mount.Format, formats) {
+			return fmt.Errorf("spec.vault.paths format '%s' must be one of %v", mount.Format, formats)
+		}
+	}
+	for _, env := range spec.Env {
+		if !oneOf(env.ValueFrom.FieldRef.FieldPath, fieldPaths) {
+			return fmt.Errorf("spec.env %s fieldPath '%s' must be one of %v", env.Name, env.ValueFrom.FieldRef.FieldPath, fieldPaths)
+		}
+	}
+
+	resources := []struct {
+		name string
+		naiserator.ResourceSpec
+	}{
+		{"limits", spec.Resources.Limits},
+		{"requests", spec.Resources.Requests},
+	}
+	for _, resource := range resources {
+		if len(resource.Cpu) > 0 && !cpuPattern.MatchString(resource.Cpu) {
+			return fmt.Errorf("spec.resources.%s.cpu '%s' must match %s", resource.name, resource.Cpu, cpuPattern)
+		}
+		if len(resource.Memory) > 0 && !memoryPattern.MatchString(resource.Memory) {
+			return fmt.Errorf("spec.resources.%s.memory '%s' must match %s", resource.name, resource.Memory, memoryPattern)
+		}
+	}
+
+	return nil
+}
+
+func oneOf(value string, allowed []string) bool {
+	for _, a := range allowed {
+		if value == a {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,99 @@
+package patch
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// markers reads the kubebuilder validation markers of the Application model, keyed by type and field name.
+func markers(t *testing.T, kind string) map[string]string {
+	path := filepath.Join("..", "models", "naiserator", "application.go")
+	file, err := parser.ParseFile(token.NewFileSet(), path, nil, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("parse model: %s", err)
+	}
+
+	prefix := "+kubebuilder:validation:" + kind + "="
+	found := make(map[string]string)
+
+	ast.Inspect(file, func(node ast.Node) bool {
+		spec, ok := node.(*ast.TypeSpec)
+		if !ok {
+			return true
+		}
+		structure, ok := spec.Type.(*ast.StructType)
+		if !ok {
+			return false
+		}
+		for _, field := range structure.Fields.List {
+			if field.Doc == nil || len(field.Names) == 0 {
+				continue
+			}
+			for _, comment := range field.Doc.List {
+				text := strings.TrimSpace(strings.TrimPrefix(comment.Text, "//"))
+				if strings.HasPrefix(text, prefix) {
+					found[spec.Name.Name+"."+field.Names[0].Name] = strings.TrimPrefix(text, prefix)
+				}
+			}
+		}
+		return false
+	})
+
+	return found
+}
+
+func enumValues(marker string) []string {
+	seen := make(map[string]bool)
+	var values []string
+	for _, value := range strings.Split(marker, ";") {
+		value = strings.Trim(value, `"`)
+		if !seen[value] {
+			seen[value] = true
+			values = append(values, value)
+		}
+	}
+	sort.Strings(values)
+	return values
+}
+
+// The allowed values used for validation must match the validation markers in the Application model.
+func TestValidationMatchesModel(t *testing.T) {
+	enums := map[string][]string{
+		"ApplicationSpec.Logformat":     logformats,
+		"ObjectFieldSelector.FieldPath": fieldPaths,
+		"SecretPath.Format":             formats,
+		"Strategy.Type":                 strategies,
+	}
+	patterns := map[string]string{
+		"ResourceSpec.Cpu":    cpuPattern.String(),
+		"ResourceSpec.Memory": memoryPattern.String(),
+	}
+
+	model := markers(t, "Enum")
+	if len(model) != len(enums) {
+		t.Errorf("the model has %d enum markers, validation checks %d", len(model), len(enums))
+	}
+	for field, allowed := range enums {
+		expected := enumValues(model[field])
+		actual := enumValues(strings.Join(allowed, ";"))
+		if !reflect.DeepEqual(actual, expected) {
+			t.Errorf("%s: validation allows %q, the model allows %q", field, actual, expected)
+		}
+	}
+
+	model = markers(t, "Pattern")
+	if len(model) != len(patterns) {
+		t.Errorf("the model has %d pattern markers, validation checks %d", len(model), len(patterns))
+	}
+	for field, pattern := range patterns {
+		if model[field] != pattern {
+			t.Errorf("%s: validation uses pattern %s, the model uses %s", field, pattern, model[field])
+		}
+	}
+}
@@ -0,0 +1,452 @@
+// Package render renders Naiserator applications into the plain Kubernetes objects Naiserator would create,
+// for use outside NAIS and for comparing the result with objects created by naisd.
+package render
+
+import (
+	"fmt"
+	"github.com/nais/migrator/cluster"
+	"github.com/nais/migrator/models/kubernetes"
+	"github.com/nais/migrator/models/naiserator"
+	"github.com/nais/migrator/report"
+	"net/url"
+	"sort"
+)
+
+const (
+	vaultImage        = "navikt/vault-sidekick:v0.3.10-d122b16"
+	vaultAddress      = "https://vault.adeo.no"
+	vaultDefaultMount = "/var/run/secrets/nais.io/vault"
+	vaultTokenFile    = vaultDefaultMount + "/vault_token"
+
+	webproxyURL     = "http://webproxy.nais:8088"
+	webproxyNoProxy = "localhost,127.0.0.1,10.254.0.1,.local,.adeo.no,.nav.no,.aetat.no,.devillo.no,.oera.no,.nais.io"
+
+	caBundlePEM = "ca-bundle-pem"
+	caBundleJKS = "ca-bundle-jks"
+	truststore  = "/etc/ssl/certs/java/cacerts"
+	servicePort = 80
+	portName    = "http"
+	source      = "kubernetes"
+)
+
+// caBundlePaths are the locations where common base images expect the CA bundle.
+var caBundlePaths = []string{
+	"/etc/ssl/certs/ca-certificates.crt",
+	"/etc/pki/tls/certs/ca-bundle.crt",
+	"/etc/ssl/ca-bundle.pem",
+	"/etc/pki/tls/cacert.pem",
+	"/etc/pki/ca-trust/extracted/pem/tls-ca-bundle.pem",
+}
+
+// Kubernetes renders an application into a ServiceAccount, Deployment, Service, HorizontalPodAutoscaler
+// and Ingress, approximating what Naiserator creates in the target cluster.
+// Parts of the application that are not rendered are listed in the report.
+func Kubernetes(app naiserator.Application, target cluster.Cluster) ([]interface{}, report.Report) {
+	var rep report.Report
+	spec := app.Spec
+
+	labels := map[string]string{"app": app.Name}
+	for k, v := range app.Labels {
+		labels[k] = v
+	}
+
+	meta := func(annotations map[string]string) kubernetes.ObjectMeta {
+		return kubernetes.ObjectMeta{
+			Name:        app.Name,
+			Namespace:   app.Namespace,
+			Labels:      labels,
+			Annotations: annotations,
+		}
+	}
+
+	objects := []interface{}{
+		kubernetes.ServiceAccount{
+			TypeMeta:   kubernetes.TypeMeta{APIVersion: "v1", Kind: "ServiceAccount"},
+			ObjectMeta: meta(nil),
+		},
+		deployment(app, target, meta(app.Annotations)),
+		kubernetes.Service{
+			TypeMeta:   kubernetes.TypeMeta{APIVersion: "v1", Kind: "Service"},
+			ObjectMeta: meta(nil),
+			Spec: kubernetes.ServiceSpec{
+				Type:     "ClusterIP",
+				Selector: map[string]string{"app": app.Name},
+				Ports: []kubernetes.ServicePort{
+					{
+						Name:       portName,
+						Protocol:   "TCP",
+						Port:       orDefault(int(spec.Service.Port), servicePort),
+						TargetPort: portName,
+					},
+				},
+			},
+		},
+		kubernetes.HorizontalPodAutoscaler{
+			TypeMeta:   kubernetes.TypeMeta{APIVersion: "autoscaling/v1", Kind: "HorizontalPodAutoscaler"},
+			ObjectMeta: meta(nil),
+			Spec: kubernetes.HorizontalPodAutoscalerSpec{
+				ScaleTargetRef: kubernetes.CrossVersionObjectReference{
+					APIVersion: "apps/v1",
+					Kind:       "Deployment",
+					Name:       app.Name,
+				},
+				MinReplicas:                    spec.Replicas.Min,
+				MaxReplicas:                    spec.Replicas.Max,
+				TargetCPUUtilizationPercentage: spec.Replicas.CpuThresholdPercentage,
+			},
+		},
+	}
+
+	rules := ingressRules(app, &rep)
+	if len(rules) > 0 {
+		objects = append(objects, kubernetes.Ingress{
+			TypeMeta:   kubernetes.TypeMeta{APIVersion: "networking.k8s.io/v1beta1", Kind: "Ingress"},
+			ObjectMeta: meta(nil),
+			Spec:       kubernetes.IngressSpec{Rules: rules},
+		})
+	}
+
+	if len(spec.AccessPolicy.Inbound.Rules) > 0 || len(spec.AccessPolicy.Outbound.Rules) > 0 || len(spec.AccessPolicy.Outbound.External) > 0 {
+		rep.Infof(source, "The access policy is not rendered; Naiserator creates network policies and Istio resources from it.")
+	}
+	if len(spec.GCP.Buckets) > 0 {
+		rep.Infof(source, "Cloud Storage buckets are not rendered.")
+	}
+	if spec.LeaderElection {
+		rep.Infof(source, "The leader election sidecar is not rendered.")
+	}
+	if spec.SecureLogs.Enabled {
+		rep.Infof(source, "The secure logs sidecar is not rendered; only the /secure-logs volume is.")
+	}
+
+	return objects, rep
+}
+
+func deployment(app naiserator.Application, target cluster.Cluster, meta kubernetes.ObjectMeta) kubernetes.Deployment {
+	spec := app.Spec
+
+	container := kubernetes.Container{
+		Name:            app.Name,
+		Image:           spec.Image,
+		ImagePullPolicy: "IfNotPresent",
+		Ports: []kubernetes.ContainerPort{
+			{Name: portName, ContainerPort: spec.Port, Protocol: "TCP"},
+		},
+		Env:            env(app, target),
+		EnvFrom:        envFrom(spec.EnvFrom),
+		Resources:      resources(spec.Resources),
+		LivenessProbe:  probe(spec.Liveness, spec.Port),
+		ReadinessProbe: probe(spec.Readiness, spec.Port),
+		Lifecycle:      lifecycle(spec.PreStopHookPath, spec.Port),
+	}
+
+	pod := kubernetes.PodSpec{
+		ServiceAccountName:            app.Name,
+		RestartPolicy:                 "Always",
+		DNSPolicy:                     "ClusterFirst",
+		TerminationGracePeriodSeconds: 30,
+	}
+
+	for i, files := range spec.FilesFrom {
+		name := fmt.Sprintf("files-%d", i)
+		volume := kubernetes.Volume{Name: name}
+		if len(files.Secret) > 0 {
+			volume.Secret = &kubernetes.SecretVolumeSource{SecretName: files.Secret}
+		} else {
+			volume.ConfigMap = &kubernetes.ConfigMapVolumeSource{Name: files.ConfigMap}
+		}
+		pod.Volumes = append(pod.Volumes, volume)
+		container.VolumeMounts = append(container.VolumeMounts, kubernetes.VolumeMount{
+			Name:      name,
+			MountPath: files.MountPath,
+			ReadOnly:  true,
+		})
+	}
+
+	if !spec.SkipCaBundle {
+		pod.Volumes = append(pod.Volumes,
+			kubernetes.Volume{Name: caBundlePEM, ConfigMap: &kubernetes.ConfigMapVolumeSource{Name: caBundlePEM}},
+			kubernetes.Volume{Name: caBundleJKS, ConfigMap: &kubernetes.ConfigMapVolumeSource{Name: caBundleJKS}},
+		)
+		for _, path := range caBundlePaths {
+			container.VolumeMounts = append(container.VolumeMounts, kubernetes.VolumeMount{
+				Name:      caBundlePEM,
+				MountPath: path,
+				SubPath:   "ca-bundle.pem",
+				ReadOnly:  true,
+			})
+		}
+		container.VolumeMounts = append(container.VolumeMounts, kubernetes.VolumeMount{
+			Name:      caBundleJKS,
+			MountPath: truststore,
+			SubPath:   "ca-bundle.jks",
+			ReadOnly:  true,
+		})
+	}
+
+	if spec.SecureLogs.Enabled {
+		pod.Volumes = append(pod.Volumes, kubernetes.Volume{Name: "secure-logs", EmptyDir: &kubernetes.EmptyDirVolumeSource{}})
+		container.VolumeMounts = append(container.VolumeMounts, kubernetes.VolumeMount{Name: "secure-logs", MountPath: "/secure-logs"})
+	}
+
+	if spec.Vault.Enabled {
+		vault(app, target, &pod, &container)
+	}
+
+	pod.Containers = append([]kubernetes.Container{container}, pod.Containers...)
+
+	strategy := kubernetes.DeploymentStrategy{Type: "RollingUpdate"}
+	if spec.Strategy != nil && len(spec.Strategy.Type) > 0 {
+		strategy.Type = spec.Strategy.Type
+	}
+	if strategy.Type == "RollingUpdate" {
+		strategy.RollingUpdate = &kubernetes.RollingUpdateDeployment{MaxUnavailable: kubernetes.FromInt(0), MaxSurge: "25%"}
+	}
+
+	return kubernetes.Deployment{
+		TypeMeta:   kubernetes.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"},
+		ObjectMeta: meta,
+		Spec: kubernetes.DeploymentSpec{
+			Replicas:                spec.Replicas.Min,
+			Selector:                kubernetes.LabelSelector{MatchLabels: map[string]string{"app": app.Name}},
+			Strategy:                strategy,
+			ProgressDeadlineSeconds: 300,
+			RevisionHistoryLimit:    10,
+			Template: kubernetes.PodTemplateSpec{
+				ObjectMeta: kubernetes.ObjectMeta{
+					Name:        app.Name,
+					Namespace:   app.Namespace,
+					Labels:      meta.Labels,
+					Annotations: podAnnotations(spec),
+				},
+				Spec: pod,
+			},
+		},
+	}
+}
+
+// env returns the environment variables Naiserator sets, followed by those of the application.
+func env(app naiserator.Application, target cluster.Cluster) []kubernetes.EnvVar {
+	spec := app.Spec
+	vars := []kubernetes.EnvVar{
+		{Name: "NAIS_APP_NAME", Value: app.Name},
+		{Name: "NAIS_NAMESPACE", Value: app.Namespace},
+		{Name: "NAIS_APP_IMAGE", Value: spec.Image},
+		{Name: "NAIS_CLUSTER_NAME", Value: target.Name},
+	}
+
+	if spec.WebProxy {
+		for _, name := range []string{"HTTP_PROXY", "HTTPS_PROXY", "http_proxy", "https_proxy"} {
+			vars = append(vars, kubernetes.EnvVar{Name: name, Value: webproxyURL})
+		}
+		vars = append(vars,
+			kubernetes.EnvVar{Name: "NO_PROXY", Value: webproxyNoProxy},
+			kubernetes.EnvVar{Name: "no_proxy", Value: webproxyNoProxy},
+		)
+	}
+
+	if !spec.SkipCaBundle {
+		vars = append(vars,
+			kubernetes.EnvVar{Name: "NAV_TRUSTSTORE_PATH", Value: truststore},
+			kubernetes.EnvVar{Name: "NAV_TRUSTSTORE_PASSWORD", Value: "changeme"},
+		)
+	}
+
+	for _, v := range spec.Env {
+		envVar := kubernetes.EnvVar{Name: v.Name, Value: v.Value}
+		if len(v.ValueFrom.FieldRef.FieldPath) > 0 {
+			envVar.ValueFrom = &kubernetes.EnvVarSource{
+				FieldRef: &kubernetes.ObjectFieldSelector{FieldPath: v.ValueFrom.FieldRef.FieldPath},
+			}
+		}
+		vars = append(vars, envVar)
+	}
+
+	return vars
+}
+
+func envFrom(sources []naiserator.EnvFrom) []kubernetes.EnvFromSource {
+	var converted []kubernetes.EnvFromSource
+	for _, from := range sources {
+		if len(from.Secret) > 0 {
+			converted = append(converted, kubernetes.EnvFromSource{SecretRef: &kubernetes.LocalObjectReference{Name: from.Secret}})
+		} else {
+			converted = append(converted, kubernetes.EnvFromSource{ConfigMapRef: &kubernetes.LocalObjectReference{Name: from.ConfigMap}})
+		}
+	}
+	return converted
+}
+
+func resources(requirements naiserator.ResourceRequirements) kubernetes.ResourceRequirements {
+	list := func(spec naiserator.ResourceSpec) map[string]string {
+		m := make(map[string]string)
+		if len(spec.Cpu) > 0 {
+			m["cpu"] = spec.Cpu
+		}
+		if len(spec.Memory) > 0 {
+			m["memory"] = spec.Memory
+		}
+		return m
+	}
+	return kubernetes.ResourceRequirements{
+		Limits:   list(requirements.Limits),
+		Requests: list(requirements.Requests),
+	}
+}
+
+func probe(p naiserator.Probe, port int) *kubernetes.Probe {
+	if len(p.Path) == 0 {
+		return nil
+	}
+	return &kubernetes.Probe{
+		HTTPGet:             &kubernetes.HTTPGetAction{Path: p.Path, Port: kubernetes.FromInt(orDefault(p.Port, port))},
+		InitialDelaySeconds: p.InitialDelay,
+		PeriodSeconds:       p.PeriodSeconds,
+		FailureThreshold:    p.FailureThreshold,
+		TimeoutSeconds:      p.Timeout,
+	}
+}
+
+// lifecycle calls the pre-stop hook if there is one, and otherwise waits for the pod to be removed from the load balancer.
+func lifecycle(preStopHookPath string, port int) *kubernetes.Lifecycle {
+	if len(preStopHookPath) > 0 {
+		return &kubernetes.Lifecycle{PreStop: &kubernetes.Handler{
+			HTTPGet: &kubernetes.HTTPGetAction{Path: preStopHookPath, Port: kubernetes.FromInt(port)},
+		}}
+	}
+	return &kubernetes.Lifecycle{PreStop: &kubernetes.Handler{
+		Exec: &kubernetes.ExecAction{Command: []string{"sleep", "5"}},
+	}}
+}
+
+func podAnnotations(spec naiserator.ApplicationSpec) map[string]string {
+	annotations := make(map[string]string)
+	if spec.Prometheus.Enabled {
+		annotations["prometheus.io/scrape"] = "true"
+		annotations["prometheus.io/port"] = spec.Prometheus.Port
+		annotations["prometheus.io/path"] = spec.Prometheus.Path
+	}
+	if len(spec.Logformat) > 0 {
+		annotations["nais.io/logformat"] = spec.Logformat
+	}
+	if len(spec.Logtransform) > 0 {
+		annotations["nais.io/logtransform"] = spec.Logtransform
+	}
+	if len(annotations) == 0 {
+		return nil
+	}
+	return annotations
+}
+
+// vault adds an init container fetching the application's secrets from Vault into memory backed volumes,
+// and a sidecar container keeping the token renewed if asked for.
+func vault(app naiserator.Application, target cluster.Cluster, pod *kubernetes.PodSpec, container *kubernetes.Container) {
+	mounts := app.Spec.Vault.Mounts
+	hasDefault := false
+	for _, mount := range mounts {
+		hasDefault = hasDefault || mount.MountPath == vaultDefaultMount
+	}
+	if !hasDefault {
+		mounts = append(mounts, naiserator.SecretPath{
+			KvPath:    fmt.Sprintf("%s/%s/%s", target.VaultKvPrefix, app.Name, app.Namespace),
+			MountPath: vaultDefaultMount,
+		})
+	}
+
+	args := []string{
+		"-v=10",
+		"-logtostderr",
+		"-vault=" + vaultAddress,
+		"-one-shot",
+		"-save-token=" + vaultTokenFile,
+	}
+	var volumeMounts []kubernetes.VolumeMount
+
+	for i, mount := range mounts {
+		name := fmt.Sprintf("vault-volume-%d", i)
+		format := mount.Format
+		if len(format) == 0 {
+			format = "flatten"
+		}
+		args = append(args, fmt.Sprintf("-cn=secret:%s:dir=%s,fmt=%s,retries=1", mount.KvPath, mount.MountPath, format))
+		pod.Volumes = append(pod.Volumes, kubernetes.Volume{Name: name, EmptyDir: &kubernetes.EmptyDirVolumeSource{Medium: "Memory"}})
+		volumeMounts = append(volumeMounts, kubernetes.VolumeMount{Name: name, MountPath: mount.MountPath})
+	}
+
+	vaultEnv := []kubernetes.EnvVar{
+		{Name: "VAULT_AUTH_METHOD", Value: "kubernetes"},
+		{Name: "VAULT_SIDEKICK_ROLE", Value: app.Name},
+		{Name: "VAULT_K8S_LOGIN_PATH", Value: fmt.Sprintf("auth/kubernetes/%s/login", target.Name)},
+	}
+
+	pod.InitContainers = append(pod.InitContainers, kubernetes.Container{
+		Name:         "vks-init",
+		Image:        vaultImage,
+		Args:         args,
+		Env:          vaultEnv,
+		VolumeMounts: volumeMounts,
+	})
+	container.VolumeMounts = append(container.VolumeMounts, volumeMounts...)
+
+	if app.Spec.Vault.Sidecar {
+		pod.Containers = append(pod.Containers, kubernetes.Container{
+			Name:  "vks-sidecar",
+			Image: vaultImage,
+			Args: []string{
+				"-v=10",
+				"-logtostderr",
+				"-vault=" + vaultAddress,
+				"-renew-token",
+				"-save-token=" + vaultTokenFile,
+			},
+			Env:          vaultEnv,
+			VolumeMounts: volumeMounts,
+		})
+	}
+}
+
+// ingressRules groups the ingress paths of the application by host.
+func ingressRules(app naiserator.Application, rep *report.Report) []kubernetes.IngressRule {
+	var hosts []string
+	paths := make(map[string][]string)
+
+	for _, ingress := range app.Spec.Ingresses {
+		u, err := url.Parse(ingress)
+		if err != nil || len(u.Host) == 0 {
+			rep.Warnf(source, "Ingress '%s' is not a URL and is not rendered.", ingress)
+			continue
+		}
+		path := u.Path
+		if len(path) == 0 {
+			path = "/"
+		}
+		if _, ok := paths[u.Host]; !ok {
+			hosts = append(hosts, u.Host)
+		}
+		paths[u.Host] = append(paths[u.Host], path)
+	}
+
+	sort.Strings(hosts)
+	port := orDefault(int(app.Spec.Service.Port), servicePort)
+	rules := make([]kubernetes.IngressRule, 0, len(hosts))
+
+	for _, host := range hosts {
+		rule := kubernetes.IngressRule{Host: host}
+		for _, path := range paths[host] {
+			rule.HTTP.Paths = append(rule.HTTP.Paths, kubernetes.HTTPIngressPath{
+				Path:    path,
+				Backend: kubernetes.IngressBackend{ServiceName: app.Name, ServicePort: kubernetes.FromInt(port)},
+			})
+		}
+		rules = append(rules, rule)
+	}
+
+	return rules
+}
+
+func orDefault(value, def int) int {
+	if value == 0 {
+		return def
+	}
+	return value
+}
@@ -0,0 +1,207 @@
+package render
+
+import (
+	"bytes"
+	"flag"
+	"github.com/nais/migrator/cluster"
+	"github.com/nais/migrator/models/kubernetes"
+	"github.com/nais/migrator/models/naiserator"
+	"github.com/nais/migrator/report"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update expected output in testdata")
+
+func devFss(t *testing.T) cluster.Cluster {
+	target, err := cluster.Lookup("fss", false)
+	if err != nil {
+		t.Fatalf("look up cluster: %s", err)
+	}
+	return target
+}
+
+func minimalApplication() naiserator.Application {
+	return naiserator.Application{
+		TypeMeta:   naiserator.TypeMeta{Kind: "Application", APIVersion: "nais.io/v1alpha1"},
+		ObjectMeta: naiserator.ObjectMeta{Name: "app", Namespace: "team", Labels: map[string]string{"team": "team"}},
+		Spec: naiserator.ApplicationSpec{
+			Image:    "docker.adeo.no:5000/team/app:1",
+			Port:     8080,
+			Replicas: naiserator.Replicas{Min: 2, Max: 4, CpuThresholdPercentage: 50},
+		},
+	}
+}
+
+func fullApplication() naiserator.Application {
+	app := minimalApplication()
+	app.Annotations = map[string]string{"nais.io/owner": "team"}
+	app.Spec = naiserator.ApplicationSpec{
+		Image: "docker.adeo.no:5000/team/app:1",
+		Port:  8443,
+		Env: []naiserator.EnvVar{
+			{Name: "FOO", Value: "bar"},
+			{Name: "POD_IP", ValueFrom: naiserator.EnvVarSource{FieldRef: naiserator.ObjectFieldSelector{FieldPath: "status.podIP"}}},
+		},
+		EnvFrom:   []naiserator.EnvFrom{{Secret: "app-env"}, {ConfigMap: "app-config"}},
+		FilesFrom: []naiserator.FilesFrom{{Secret: "app-certificates", MountPath: "/var/run/secrets/nais.io/certificates/app"}},
+		Ingresses: []string{
+			"https://app.nais.preprod.local",
+			"https://app.dev.adeo.no/api",
+			"https://app.dev.adeo.no/internal",
+			"not a url",
+		},
+		Liveness:        naiserator.Probe{Path: "/isalive", InitialDelay: 20, PeriodSeconds: 10, FailureThreshold: 3, Timeout: 1},
+		Readiness:       naiserator.Probe{Path: "/isready", Port: 8081, InitialDelay: 20, PeriodSeconds: 10, FailureThreshold: 3, Timeout: 1},
+		PreStopHookPath: "/stop",
+		Prometheus:      naiserator.PrometheusConfig{Enabled: true, Port: "8443", Path: "/metrics"},
+		Replicas:        naiserator.Replicas{Min: 1, Max: 6, CpuThresholdPercentage: 70},
+		Resources: naiserator.ResourceRequirements{
+			Limits:   naiserator.ResourceSpec{Cpu: "1", Memory: "1024Mi"},
+			Requests: naiserator.ResourceSpec{Cpu: "250m", Memory: "512Mi"},
+		},
+		Service:      naiserator.Service{Port: 8080},
+		Strategy:     &naiserator.Strategy{Type: "Recreate"},
+		Logformat:    "accesslog",
+		Logtransform: "http_loglevel",
+		SecureLogs:   naiserator.SecureLogs{Enabled: true},
+		Vault: naiserator.Vault{
+			Enabled: true,
+			Sidecar: true,
+			Mounts: []naiserator.SecretPath{
+				{KvPath: "/kv/preprod/fss/app/team/srvapp", MountPath: "/var/run/secrets/nais.io/srvapp", Format: "env"},
+			},
+		},
+		WebProxy:       true,
+		LeaderElection: true,
+		AccessPolicy: naiserator.AccessPolicy{
+			Outbound: naiserator.AccessPolicyOutbound{External: []naiserator.AccessPolicyExternalRule{{Host: "mq.adeo.no"}}},
+		},
+	}
+	return app
+}
+
+// renderedOutput is what a rendering is compared with: the objects as they are written by the migrator, and the findings.
+func renderedOutput(t *testing.T, objects []interface{}, rep report.Report) []byte {
+	var buf bytes.Buffer
+	for _, object := range objects {
+		data, err := yaml.Marshal(object)
+		if err != nil {
+			t.Fatalf("encode object: %s", err)
+		}
+		buf.WriteString("---\n")
+		buf.Write(data)
+	}
+
+	data, err := yaml.Marshal(rep)
+	if err != nil {
+		t.Fatalf("encode report: %s", err)
+	}
+	buf.WriteString("---\n")
+	buf.Write(data)
+
+	return buf.Bytes()
+}
+
+func TestKubernetes(t *testing.T) {
+	tests := map[string]naiserator.Application{
+		"minimal": minimalApplication(),
+		"full":    fullApplication(),
+	}
+
+	for name, app := range tests {
+		t.Run(name, func(t *testing.T) {
+			objects, rep := Kubernetes(app, devFss(t))
+			actual := renderedOutput(t, objects, rep)
+
+			path := filepath.Join("testdata", name+".yaml")
+			if *update {
+				err := ioutil.WriteFile(path, actual, 0644)
+				if err != nil {
+					t.Fatalf("update %s: %s", path, err)
+				}
+				return
+			}
+
+			expected, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatalf("read %s: %s", path, err)
+			}
+			if !bytes.Equal(expected, actual) {
+				t.Errorf("output differs from %s; run with -update if the change is intended\n%s", path, actual)
+			}
+		})
+	}
+}
+
+func TestKubernetesObjects(t *testing.T) {
+	objects, _ := Kubernetes(minimalApplication(), devFss(t))
+
+	var kinds []string
+	for _, object := range objects {
+		data, err := yaml.Marshal(object)
+		if err != nil {
+			t.Fatalf("encode object: %s", err)
+		}
+		var meta kubernetes.TypeMeta
+		err = yaml.Unmarshal(data, &meta)
+		if err != nil {
+			t.Fatalf("decode object: %s", err)
+		}
+		kinds = append(kinds, meta.Kind)
+	}
+
+	// Without ingresses, no Ingress is rendered.
+	expected := []string{"ServiceAccount", "Deployment", "Service", "HorizontalPodAutoscaler"}
+	if len(kinds) != len(expected) {
+		t.Fatalf("rendered %v, want %v", kinds, expected)
+	}
+	for i := range kinds {
+		if kinds[i] != expected[i] {
+			t.Fatalf("rendered %v, want %v", kinds, expected)
+		}
+	}
+
+	deployment := objects[1].(kubernetes.Deployment)
+	if deployment.Spec.Strategy.Type != "RollingUpdate" || deployment.Spec.Strategy.RollingUpdate == nil {
+		t.Errorf("the deployment strategy defaults to a rolling update, got %+v", deployment.Spec.Strategy)
+	}
+	if deployment.Spec.Replicas != 2 {
+		t.Errorf("the deployment starts with %d replicas, want the minimum of 2", deployment.Spec.Replicas)
+	}
+
+	service := objects[2].(kubernetes.Service)
+	if port := service.Spec.Ports[0].Port; port != servicePort {
+		t.Errorf("the service port is %d, want %d", port, servicePort)
+	}
+
+	hpa := objects[3].(kubernetes.HorizontalPodAutoscaler)
+	if hpa.Spec.MinReplicas != 2 || hpa.Spec.MaxReplicas != 4 || hpa.Spec.TargetCPUUtilizationPercentage != 50 {
+		t.Errorf("the autoscaler does not follow the replicas, got %+v", hpa.Spec)
+	}
+}
+
+func TestIngressRules(t *testing.T) {
+	app := fullApplication()
+	var rep report.Report
+
+	rules := ingressRules(app, &rep)
+
+	if len(rules) != 2 {
+		t.Fatalf("expected a rule for each of the 2 hosts, got %+v", rules)
+	}
+	if rules[0].Host != "app.dev.adeo.no" || len(rules[0].HTTP.Paths) != 2 {
+		t.Errorf("paths on the same host share a rule, got %+v", rules[0])
+	}
+	if rules[1].Host != "app.nais.preprod.local" || rules[1].HTTP.Paths[0].Path != "/" {
+		t.Errorf("ingresses without a path get '/', got %+v", rules[1])
+	}
+	if backend := rules[0].HTTP.Paths[0].Backend; backend.ServiceName != "app" || backend.ServicePort != kubernetes.FromInt(8080) {
+		t.Errorf("ingresses point at the service port, got %+v", backend)
+	}
+	if len(rep.Findings) != 1 || rep.Findings[0].Severity != report.Warning {
+		t.Errorf("expected a warning for the ingress that is not a URL, got %v", rep.Findings)
+	}
+}
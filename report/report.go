@@ -0,0 +1,94 @@
+// Package report collects findings made during a migration that need the attention of the team.
+package report
+
+import (
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+	"io"
+)
+
+// Severity tells how much attention a finding needs.
+type Severity string
+
+const (
+	// Info findings describe a decision made on behalf of the team.
+	Info Severity = "info"
+	// Warning findings describe something that must be checked or done by hand.
+	Warning Severity = "warning"
+	// Error findings describe something that will not work after migration.
+	Error Severity = "error"
+)
+
+// Finding is a single observation made during migration.
+type Finding struct {
+	Severity Severity `yaml:"severity"`
+	// Source is what the finding concerns, such as a Fasit resource or a field in the manifest.
+	Source  string `yaml:"source"`
+	Message string `yaml:"message"`
+}
+
+// Report is the collection of findings from a migration.
+type Report struct {
+	Findings []Finding `yaml:"findings"`
+}
+
+// Add records a finding.
+func (r *Report) Add(severity Severity, source, format string, args ...interface{}) {
+	r.Findings = append(r.Findings, Finding{
+		Severity: severity,
+		Source:   source,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// Infof records a finding with severity Info.
+func (r *Report) Infof(source, format string, args ...interface{}) {
+	r.Add(Info, source, format, args...)
+}
+
+// Warnf records a finding with severity Warning.
+func (r *Report) Warnf(source, format string, args ...interface{}) {
+	r.Add(Warning, source, format, args...)
+}
+
+// Errorf records a finding with severity Error.
+func (r *Report) Errorf(source, format string, args ...interface{}) {
+	r.Add(Error, source, format, args...)
+}
+
+// Merge appends the findings from another report.
+func (r *Report) Merge(other Report) {
+	r.Findings = append(r.Findings, other.Findings...)
+}
+
+// Count returns the number of findings with the given severity.
+func (r Report) Count(severity Severity) int {
+	count := 0
+	for _, finding := range r.Findings {
+		if finding.Severity == severity {
+			count++
+		}
+	}
+	return count
+}
+
+// Log writes all findings to the log, using a log level matching their severity.
+func (r Report) Log() {
+	for _, finding := range r.Findings {
+		entry := log.WithField("source", finding.Source)
+		switch finding.Severity {
+		case Error:
+			entry.Error(finding.Message)
+		case Warning:
+			entry.Warn(finding.Message)
+		default:
+			entry.Info(finding.Message)
+		}
+	}
+}
+
+// Write encodes the report as YAML.
+func (r Report) Write(w io.Writer) error {
+	return yaml.NewEncoder(w).Encode(r)
+}